@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/redeam/gobooks/objects"
+	"github.com/redeam/gobooks/store"
+)
+
+// APIKeyHeader header carrying the API key of a request
+const APIKeyHeader = "X-API-Key"
+
+type apiKeys struct {
+	keys store.IKeyStore
+}
+
+// APIKeys authenticates requests with the API keys of the store, sent in the X-API-Key header
+func APIKeys(keys store.IKeyStore) Authenticator {
+	return &apiKeys{keys: keys}
+}
+
+func (a *apiKeys) Challenge() string {
+	return `ApiKey header="` + APIKeyHeader + `"`
+}
+
+func (a *apiKeys) Authenticate(r *http.Request) (*objects.Principal, error) {
+	secret := r.Header.Get(APIKeyHeader)
+	if secret == "" {
+		return nil, nil
+	}
+	key, err := a.keys.FindKey(r.Context(), secret)
+	if err != nil {
+		return nil, err
+	}
+	return &objects.Principal{Subject: "key:" + key.Name, ID: "key:" + key.ID, Role: key.Scope.Role(), Tenant: key.TenantID}, nil
+}
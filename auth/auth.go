@@ -0,0 +1,75 @@
+// Package auth authenticates the clients of the API and checks what they are allowed to do.
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/handlers"
+	"github.com/redeam/gobooks/objects"
+)
+
+// Authenticator authenticates the requests carrying its kind of credentials
+type Authenticator interface {
+	// Authenticate returns the client of a request, nil when the request has no credentials of
+	// its kind, or ErrUnauthorized when they are invalid
+	Authenticate(r *http.Request) (*objects.Principal, error)
+	// Challenge WWW-Authenticate challenge telling clients how to authenticate
+	Challenge() string
+}
+
+// Middleware authenticates requests with the first authenticator finding credentials, and checks
+// the policy allows the client's role, anonymous without credentials, on the route. Clients can
+// only make requests to their own library
+func Middleware(policy Policy, authenticators ...Authenticator) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var p *objects.Principal
+			for _, a := range authenticators {
+				var err error
+				if p, err = a.Authenticate(r); err != nil {
+					w.Header().Set("WWW-Authenticate", a.Challenge())
+					handlers.WriteError(w, err)
+					return
+				}
+				if p != nil {
+					break
+				}
+			}
+			if p != nil && p.Tenant != objects.TenantFromContext(r.Context()).ID {
+				handlers.WriteError(w, errors.ErrForbidden)
+				return
+			}
+			role := objects.RoleAnonymous
+			if p != nil {
+				role = p.Role
+			}
+			if !policy.Allows(routeName(r), string(role)) {
+				if p != nil {
+					handlers.WriteError(w, errors.ErrForbidden)
+					return
+				}
+				for _, a := range authenticators {
+					w.Header().Add("WWW-Authenticate", a.Challenge())
+				}
+				handlers.WriteError(w, errors.ErrUnauthorized)
+				return
+			}
+			if p != nil {
+				// changes are recorded as made by the client
+				ctx := objects.WithPrincipal(r.Context(), p)
+				r = r.WithContext(objects.WithActor(ctx, p.Subject))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// routeName name of the route of the request, the key of its permissions in a policy
+func routeName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		return route.GetName()
+	}
+	return ""
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256" // hashes of the RS256 and ES256 signatures
+	_ "crypto/sha512" // hashes of the RS384, RS512, ES384 and ES512 signatures
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+)
+
+// OIDCConfig settings of the bearer tokens issued by an OIDC identity provider
+type OIDCConfig struct {
+	// JWKSURL URL of the provider's signing keys,
+	// e.g "https://login.example.com/.well-known/jwks.json"
+	JWKSURL string
+	// Issuer expected iss claim of the tokens
+	Issuer string
+	// Audience expected in the aud claim of the tokens
+	Audience string
+	// ClockSkew tolerated between the provider's clock and ours, when checking exp and nbf
+	ClockSkew time.Duration
+	// CacheTTL how long the signing keys are cached, an hour by default
+	CacheTTL time.Duration
+	// Client used to fetch the signing keys, http.DefaultClient by default
+	Client *http.Client
+}
+
+// signingAlgs hashes of the supported signature algorithms, symmetric algorithms and none are
+// refused so that tokens can't be signed with anything but the provider's keys
+var signingAlgs = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+type bearer struct {
+	cfg  OIDCConfig
+	keys *keySet
+	now  func() time.Time
+}
+
+// Bearer authenticates requests with the JWTs of an OIDC provider, sent in the Authorization
+// header. The principal is the token's subject, with the highest role listed in its roles claim,
+// or else the role of the highest API scope listed in its scope (or scp) claim, in the library
+// of its tenant claim, the default one without
+func Bearer(cfg OIDCConfig) Authenticator {
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = time.Hour
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &bearer{
+		cfg:  cfg,
+		keys: &keySet{url: cfg.JWKSURL, ttl: cfg.CacheTTL, client: cfg.Client},
+		now:  time.Now,
+	}
+}
+
+func (b *bearer) Challenge() string {
+	return `Bearer realm="gobooks"`
+}
+
+func (b *bearer) Authenticate(r *http.Request) (*objects.Principal, error) {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "Bearer ") {
+		return nil, nil
+	}
+	claims, err := b.verify(r, strings.TrimSpace(h[7:]))
+	if err != nil {
+		log.Println(err)
+		return nil, errors.ErrUnauthorized
+	}
+	role, ok := objects.HighestRole(claims.Roles)
+	if !ok {
+		role = objects.HighestScope(claims.scopes()).Role()
+	}
+	tenant := claims.Tenant
+	if tenant == "" {
+		tenant = objects.DefaultTenantID
+	}
+	return &objects.Principal{Subject: claims.Subject, ID: "sub:" + claims.Subject, Role: role, Tenant: tenant}, nil
+}
+
+// header JOSE header of a token
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// claims registered claims of a token, the scopes, roles and library
+type claims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  audience        `json:"aud"`
+	Expires   *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Scp       json.RawMessage `json:"scp"`
+	Roles     []string        `json:"roles"`
+	Tenant    string          `json:"tenant"`
+}
+
+// audience aud claim, a single string or an array of them
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// scopes of a token, from the space separated scope claim or the scp claim, a string or an array
+func (c *claims) scopes() []string {
+	if c.Scope != "" {
+		return strings.Fields(c.Scope)
+	}
+	var list []string
+	if err := json.Unmarshal(c.Scp, &list); err == nil {
+		return list
+	}
+	var s string
+	_ = json.Unmarshal(c.Scp, &s)
+	return strings.Fields(s)
+}
+
+// verify checks the signature of a token, then that it was issued by the provider for this api
+// and is valid now
+func (b *bearer) verify(r *http.Request, token string) (*claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("jwt: malformed token")
+	}
+	hdr := &header{}
+	if err := decodeSegment(parts[0], hdr); err != nil {
+		return nil, err
+	}
+	hash, ok := signingAlgs[hdr.Alg]
+	if !ok {
+		return nil, fmt.Errorf("jwt: unsupported algorithm %q", hdr.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("jwt: %v", err)
+	}
+	key, err := b.keys.key(r.Context(), hdr.Kid)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if err := verifySignature(hdr.Alg, key, h.Sum(nil), hash, sig); err != nil {
+		return nil, err
+	}
+
+	c := &claims{}
+	if err := decodeSegment(parts[1], c); err != nil {
+		return nil, err
+	}
+	if c.Issuer != b.cfg.Issuer {
+		return nil, fmt.Errorf("jwt: unexpected issuer %q", c.Issuer)
+	}
+	if !c.Audience.has(b.cfg.Audience) {
+		return nil, fmt.Errorf("jwt: not issued for %q", b.cfg.Audience)
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("jwt: no subject")
+	}
+	now := b.now()
+	if c.Expires == nil || !now.Before(time.Unix(*c.Expires, 0).Add(b.cfg.ClockSkew)) {
+		return nil, fmt.Errorf("jwt: token expired")
+	}
+	if c.NotBefore != nil && now.Before(time.Unix(*c.NotBefore, 0).Add(-b.cfg.ClockSkew)) {
+		return nil, fmt.Errorf("jwt: token not valid yet")
+	}
+	return c, nil
+}
+
+func (a audience) has(aud string) bool {
+	for _, s := range a {
+		if s == aud {
+			return true
+		}
+	}
+	return false
+}
+
+func verifySignature(alg string, key crypto.PublicKey, digest []byte, hash crypto.Hash, sig []byte) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[:2] == "RS" {
+			return rsa.VerifyPKCS1v15(k, hash, digest, sig)
+		}
+	case *ecdsa.PublicKey:
+		// ES signatures are r and s, each padded to the size of the curve
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] == "ES" && len(sig) == 2*size {
+			r := new(big.Int).SetBytes(sig[:size])
+			s := new(big.Int).SetBytes(sig[size:])
+			if ecdsa.Verify(k, digest, r, s) {
+				return nil
+			}
+			return fmt.Errorf("jwt: invalid signature")
+		}
+	}
+	return fmt.Errorf("jwt: key doesn't match algorithm %s", alg)
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("jwt: %v", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("jwt: %v", err)
+	}
+	return nil
+}
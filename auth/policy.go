@@ -0,0 +1,81 @@
+package auth
+
+import (
+	_ "embed" // default policy
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/gorilla/mux"
+	"github.com/redeam/gobooks/objects"
+)
+
+//go:embed policy.json
+var defaultPolicy []byte
+
+// Policy permission matrix of the api, the roles allowed on each named route. Routes it doesn't
+// name are denied to everyone
+type Policy map[string][]string
+
+// DefaultPolicy policy letting anyone read the catalog, librarians change it and admins also
+// purge the trash and manage keys and libraries
+func DefaultPolicy() Policy {
+	p, err := ParsePolicy(defaultPolicy)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// LoadPolicy reads a policy file, a JSON object mapping route names to the roles allowed on them
+func LoadPolicy(path string) (Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses a JSON policy, refusing unknown roles so that typos don't silently deny
+func ParsePolicy(data []byte) (Policy, error) {
+	p := Policy{}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("policy: %v", err)
+	}
+	for route, roles := range p {
+		for _, role := range roles {
+			if !objects.IsRole(role) {
+				return nil, fmt.Errorf("policy: unknown role %q for %s", role, route)
+			}
+		}
+	}
+	return p, nil
+}
+
+// Allows reports whether the role is allowed on the route
+func (p Policy) Allows(route, role string) bool {
+	for _, r := range p[route] {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing names of the routes of the router the policy doesn't name, so no one is allowed on
+func (p Policy) Missing(router *mux.Router) []string {
+	var missing []string
+	_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		// path prefixes and subrouters have no handler of their own
+		if route.GetHandler() == nil {
+			return nil
+		}
+		if _, ok := p[route.GetName()]; !ok {
+			tpl, _ := route.GetPathTemplate()
+			methods, _ := route.GetMethods()
+			missing = append(missing, fmt.Sprintf("%s %v %s", route.GetName(), methods, tpl))
+		}
+		return nil
+	})
+	return missing
+}
@@ -0,0 +1,193 @@
+// Package citation renders Books as bibliographic citations, in BibTeX, RIS and CSL-JSON,
+// with citation keys derived from their author, year and id.
+package citation
+
+import (
+	"hash/fnv"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/redeam/gobooks/objects"
+)
+
+// Format citation format and its media type
+type Format struct {
+	Name        string
+	ContentType string
+	write       func(w io.Writer, entries []*entry) error
+}
+
+// Supported citation formats
+var (
+	BibTeX  = &Format{Name: "bibtex", ContentType: "application/x-bibtex", write: writeBibTeX}
+	RIS     = &Format{Name: "ris", ContentType: "application/x-research-info-systems", write: writeRIS}
+	CSLJSON = &Format{Name: "csl-json", ContentType: "application/vnd.citationstyles.csl+json", write: writeCSLJSON}
+)
+
+var formats = []*Format{BibTeX, RIS, CSLJSON}
+
+// ByName citation format of the given name, nil if unknown
+func ByName(name string) *Format {
+	for _, f := range formats {
+		if strings.EqualFold(f.Name, name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// Write renders the books as citations
+func (f *Format) Write(w io.Writer, books ...*objects.Book) error {
+	return f.write(w, entries(books))
+}
+
+// entry book with its citation key and parsed author names and date
+type entry struct {
+	*objects.Book
+	Key     string
+	Authors []*Name
+	Date    []int
+}
+
+// Name author name, Literal is set for names that can't be split
+type Name struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+func (n *Name) inverted() string {
+	if n.Literal != "" {
+		return n.Literal
+	}
+	if n.Given == "" {
+		return n.Family
+	}
+	return n.Family + ", " + n.Given
+}
+
+// entries parses the books and keys them with BookKey
+func entries(books []*objects.Book) []*entry {
+	res := make([]*entry, len(books))
+	for i, bk := range books {
+		e := &entry{Book: bk, Authors: ParseAuthors(bk.Author), Date: parseDate(bk.PublishDate)}
+		e.Key = Key(e.Authors, e.Date) + idSuffix(bk.ID)
+		res[i] = e
+	}
+	return res
+}
+
+// BookKey citation key of a book, its Key followed by letters derived from its id, e.g
+// smith2001kqzt. A book gets the same key wherever it is cited, and books sharing their
+// author and year get different ones
+func BookKey(bk *objects.Book) string {
+	return Key(ParseAuthors(bk.Author), parseDate(bk.PublishDate)) + idSuffix(bk.ID)
+}
+
+// idSuffixLength number of letters of the suffix of a key, telling apart
+// 26^4 books of the same author and year
+const idSuffixLength = 4
+
+// idSuffix letters of the hash of the id of a book, empty for books without one
+func idSuffix(id string) string {
+	if id == "" {
+		return ""
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	sum := h.Sum32()
+	suffix := make([]byte, idSuffixLength)
+	for i := range suffix {
+		suffix[i] = byte('a' + sum%26)
+		sum /= 26
+	}
+	return string(suffix)
+}
+
+// Key citation key of the first author's family name and the year, e.g smith2001,
+// anon when there is no author and nd when there is no year
+func Key(authors []*Name, date []int) string {
+	key := "anon"
+	if len(authors) > 0 {
+		name := authors[0].Family
+		if name == "" {
+			name = authors[0].Literal
+		}
+		var b strings.Builder
+		for _, r := range strings.ToLower(name) {
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				b.WriteRune(r)
+			}
+		}
+		if b.Len() > 0 {
+			key = b.String()
+		}
+	}
+	if len(date) == 0 {
+		return key + "nd"
+	}
+	return key + strconv.Itoa(date[0])
+}
+
+var authorSeparator = regexp.MustCompile(`\s*;\s*|\s+(?:and|&)\s+`)
+
+// ParseAuthors splits the author of a book into names, accepting "Smith, Zadie",
+// "Zadie Smith" and lists of them separated by ";", "and" or commas
+func ParseAuthors(author string) []*Name {
+	var res []*Name
+	for _, part := range authorSeparator.Split(strings.TrimSpace(author), -1) {
+		if part == "" {
+			continue
+		}
+		names := strings.Split(part, ",")
+		// "Smith, Zadie" is a single inverted name, "Zadie Smith, Jane Doe" a list
+		if len(names) == 2 && !strings.Contains(strings.TrimSpace(names[1]), " ") {
+			res = append(res, &Name{Family: strings.TrimSpace(names[0]), Given: strings.TrimSpace(names[1])})
+			continue
+		}
+		for _, name := range names {
+			if name = strings.TrimSpace(name); name != "" {
+				res = append(res, parseName(name))
+			}
+		}
+	}
+	return res
+}
+
+func parseName(name string) *Name {
+	fields := strings.Fields(name)
+	if len(fields) == 1 {
+		return &Name{Literal: name}
+	}
+	return &Name{Family: fields[len(fields)-1], Given: strings.Join(fields[:len(fields)-1], " ")}
+}
+
+var (
+	datePattern = regexp.MustCompile(`^(\d{4})(?:-(\d{1,2})(?:-(\d{1,2}))?)?`)
+	yearPattern = regexp.MustCompile(`\d{4}`)
+)
+
+// parseDate year, month and day of a publish date, as far as they are known
+func parseDate(d string) []int {
+	m := datePattern.FindStringSubmatch(strings.TrimSpace(d))
+	if m == nil {
+		// dates such as "c2001." or "January 2001" still have a year
+		if y := yearPattern.FindString(d); y != "" {
+			m = []string{y, y}
+		} else {
+			return nil
+		}
+	}
+	var res []int
+	for _, part := range m[1:] {
+		if part == "" {
+			break
+		}
+		n, _ := strconv.Atoi(part)
+		res = append(res, n)
+	}
+	return res
+}
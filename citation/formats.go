@@ -0,0 +1,112 @@
+package citation
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var bibTeXEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`, "{", `\{`, "}", `\}`, "&", `\&`, "%", `\%`, "$", `\$`, "#", `\#`, "_", `\_`,
+)
+
+func writeBibTeX(w io.Writer, entries []*entry) error {
+	bw := bufio.NewWriter(w)
+	for i, e := range entries {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		fmt.Fprintf(bw, "@book{%s,\n", e.Key)
+		field := func(name, value string) {
+			if value != "" {
+				fmt.Fprintf(bw, "  %s = {%s},\n", name, bibTeXEscaper.Replace(value))
+			}
+		}
+		names := make([]string, len(e.Authors))
+		for j, n := range e.Authors {
+			names[j] = n.inverted()
+		}
+		field("author", strings.Join(names, " and "))
+		field("title", e.Title)
+		field("publisher", e.Publisher)
+		if len(e.Date) > 0 {
+			field("year", fmt.Sprint(e.Date[0]))
+		}
+		field("isbn", e.ISBN)
+		bw.WriteString("}\n")
+	}
+	return bw.Flush()
+}
+
+func writeRIS(w io.Writer, entries []*entry) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		// RIS lines are "TAG  - value", terminated by CRLF
+		tag := func(name, value string) {
+			if value != "" {
+				fmt.Fprintf(bw, "%s  - %s\r\n", name, value)
+			}
+		}
+		tag("TY", "BOOK")
+		tag("ID", e.Key)
+		for _, n := range e.Authors {
+			tag("AU", n.inverted())
+		}
+		tag("TI", e.Title)
+		tag("PB", e.Publisher)
+		if len(e.Date) > 0 {
+			tag("PY", fmt.Sprint(e.Date[0]))
+			tag("DA", risDate(e.Date))
+		}
+		tag("SN", e.ISBN)
+		bw.WriteString("ER  - \r\n")
+	}
+	return bw.Flush()
+}
+
+// risDate date as YYYY/MM/DD, with the unknown parts left empty
+func risDate(date []int) string {
+	parts := []string{fmt.Sprintf("%04d", date[0]), "", ""}
+	for i, n := range date[1:] {
+		parts[i+1] = fmt.Sprintf("%02d", n)
+	}
+	return strings.Join(parts, "/")
+}
+
+// cslItem CSL-JSON item of a book
+type cslItem struct {
+	ID        string   `json:"id"`
+	Type      string   `json:"type"`
+	Title     string   `json:"title,omitempty"`
+	Author    []*Name  `json:"author,omitempty"`
+	Publisher string   `json:"publisher,omitempty"`
+	Issued    *cslDate `json:"issued,omitempty"`
+	ISBN      string   `json:"ISBN,omitempty"`
+}
+
+type cslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+func writeCSLJSON(w io.Writer, entries []*entry) error {
+	items := make([]*cslItem, len(entries))
+	for i, e := range entries {
+		items[i] = &cslItem{
+			ID:        e.Key,
+			Type:      "book",
+			Title:     e.Title,
+			Author:    e.Authors,
+			Publisher: e.Publisher,
+			ISBN:      e.ISBN,
+		}
+		if len(e.Date) > 0 {
+			items[i].Issued = &cslDate{DateParts: [][]int{e.Date}}
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items)
+}
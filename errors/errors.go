@@ -10,59 +10,521 @@ var (
 	// ErrInternal HTTP 500
 	ErrInternal = &Error{
 		Code:    http.StatusInternalServerError,
+		Key:     "internal",
 		Message: "Something went wrong",
 	}
 	// ErrUnprocessableEntity HTTP 422
 	ErrUnprocessableEntity = &Error{
 		Code:    http.StatusUnprocessableEntity,
-		Message: "Unprocessable Entity",
+		Key:     "unreadable_body",
+		Message: "The request body could not be read",
 	}
 	// ErrBadRequest HTTP 400
 	ErrBadRequest = &Error{
 		Code:    http.StatusBadRequest,
-		Message: "Error invalid argument",
+		Key:     "invalid_argument",
+		Message: "Invalid argument",
 	}
 	// ErrStatusIsRequired HTTP 400
 	ErrStatusIsRequired = &Error{
 		Code:    http.StatusBadRequest,
-		Message: "Please a provide Status of CheckedIn or CheckedOut",
+		Key:     "invalid_status",
+		Message: "Please provide a status of CheckedIn or CheckedOut",
 	}
 	// ErrRatingIsRequired HTTP 400
 	ErrRatingIsRequired = &Error{
 		Code:    http.StatusBadRequest,
-		Message: "Rating must be 1-3",
+		Key:     "invalid_rating",
+		Message: "Rating must be between 1 and the library's highest rating",
+	}
+	// ErrInvalidFields HTTP 400
+	ErrInvalidFields = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_fields",
+		Message: "Some fields are invalid",
+	}
+	// ErrFieldTooLong HTTP 400
+	ErrFieldTooLong = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "field_too_long",
+		Message: "The field is too long",
+	}
+	// ErrInvalidISBN HTTP 400
+	ErrInvalidISBN = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_isbn",
+		Message: "ISBN should be a valid ISBN-10 or ISBN-13",
 	}
 	// ErrNotFound HTTP 404
 	ErrBookNotFound = &Error{
 		Code:    http.StatusNotFound,
+		Key:     "book_not_found",
 		Message: "Book not found",
 	}
 	// ErrObjectIsRequired HTTP 400
 	ErrObjectIsRequired = &Error{
 		Code:    http.StatusBadRequest,
+		Key:     "body_required",
 		Message: "Request object should be provided",
 	}
 	// ErrValidBookIDIsRequired HTTP 400
 	ErrValidBookIdIsRequired = &Error{
 		Code:    http.StatusBadRequest,
+		Key:     "book_id_required",
 		Message: "A valid book id is required",
 	}
 	// ErrValidBookIDIsRequired HTTP 400
 	ErrTitleandAuthorIsRequired = &Error{
 		Code:    http.StatusBadRequest,
+		Key:     "title_and_author_required",
 		Message: "A title and author are required",
 	}
 	// ErrInvalidLimit HTTP 400
 	ErrInvalidLimit = &Error{
 		Code:    http.StatusBadRequest,
+		Key:     "invalid_limit",
 		Message: "Limit should be an integral value",
 	}
+	// ErrInvalidRetention HTTP 400
+	ErrInvalidRetention = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_retention",
+		Message: "Retention should be a duration, e.g 720h",
+	}
+	// ErrInvalidTimestamp HTTP 400
+	ErrInvalidTimestamp = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_timestamp",
+		Message: "Timestamp should be in RFC 3339 format, e.g 2021-06-01T10:00:00Z",
+	}
+	// ErrInvalidRevision HTTP 400
+	ErrInvalidRevision = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_revision",
+		Message: "Revision should be a positive integral value",
+	}
+	// ErrRevisionNotFound HTTP 404
+	ErrRevisionNotFound = &Error{
+		Code:    http.StatusNotFound,
+		Key:     "revision_not_found",
+		Message: "Revision not found",
+	}
+	// ErrDeletedSinceRevision HTTP 409
+	ErrDeletedSinceRevision = &Error{
+		Code:    http.StatusConflict,
+		Key:     "deleted_since_revision",
+		Message: "Book has been deleted since that revision",
+	}
+	// ErrInvalidBatchSize HTTP 400
+	ErrInvalidBatchSize = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_batch_size",
+		Message: "A batch should have between 1 and 1000 operations",
+	}
+	// ErrInvalidBatchMode HTTP 400
+	ErrInvalidBatchMode = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_batch_mode",
+		Message: "Batch mode should be atomic or best_effort",
+	}
+	// ErrInvalidOperation HTTP 400
+	ErrInvalidOperation = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_operation",
+		Message: "Operation should be create, update or delete",
+	}
+	// ErrBatchAborted HTTP 424
+	ErrBatchAborted = &Error{
+		Code:    http.StatusFailedDependency,
+		Key:     "batch_aborted",
+		Message: "Not applied, another operation of the batch failed",
+	}
+	// ErrInvalidCSV HTTP 400
+	ErrInvalidCSV = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_csv",
+		Message: "CSV could not be parsed",
+	}
+	// ErrUnknownCSVColumn HTTP 400
+	ErrUnknownCSVColumn = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "unknown_csv_column",
+		Message: "CSV header has a column that doesn't map to a book field",
+	}
+	// ErrInvalidUpsert HTTP 400
+	ErrInvalidUpsert = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_upsert",
+		Message: "Upsert should be isbn",
+	}
+	// ErrDuplicateISBN HTTP 400
+	ErrDuplicateISBN = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "duplicate_isbn",
+		Message: "ISBN appears more than once in the import",
+	}
+	// ErrInvalidImport HTTP 400
+	ErrInvalidImport = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_import",
+		Message: "Some rows are invalid, nothing was imported",
+	}
+	// ErrInvalidMARC HTTP 400
+	ErrInvalidMARC = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_marc",
+		Message: "MARC records could not be parsed",
+	}
+	// ErrInvalidMARCFormat HTTP 400
+	ErrInvalidMARCFormat = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_marc_format",
+		Message: "Format should be marc or marcxml",
+	}
+	// ErrInvalidCitationFormat HTTP 400
+	ErrInvalidCitationFormat = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_citation_format",
+		Message: "Format should be json, bibtex, ris or csl-json",
+	}
+	// ErrInvalidONIX HTTP 400
+	ErrInvalidONIX = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_onix",
+		Message: "ONIX feed could not be parsed",
+	}
+	// ErrRecordReferenceIsRequired HTTP 400
+	ErrRecordReferenceIsRequired = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "record_reference_required",
+		Message: "A product record reference is required",
+	}
+	// ErrRecordReferenceExists HTTP 409
+	ErrRecordReferenceExists = &Error{
+		Code:    http.StatusConflict,
+		Key:     "record_reference_exists",
+		Message: "A book with this record reference already exists",
+	}
+	// ErrRecordReferenceInTrash HTTP 409
+	ErrRecordReferenceInTrash = &Error{
+		Code:    http.StatusConflict,
+		Key:     "record_reference_in_trash",
+		Message: "The book with this record reference is in the trash",
+	}
+	// ErrKeyNameIsRequired HTTP 400
+	ErrKeyNameIsRequired = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "key_name_required",
+		Message: "A key name is required",
+	}
+	// ErrInvalidScope HTTP 400
+	ErrInvalidScope = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_scope",
+		Message: "Scope should be read, read_write or admin",
+	}
+	// ErrUnauthorized HTTP 401
+	ErrUnauthorized = &Error{
+		Code:    http.StatusUnauthorized,
+		Key:     "unauthorized",
+		Message: "A valid API key or bearer token is required",
+	}
+	// ErrForbidden HTTP 403
+	ErrForbidden = &Error{
+		Code:    http.StatusForbidden,
+		Key:     "forbidden",
+		Message: "You are not allowed to make this request",
+	}
+	// ErrKeyNotFound HTTP 404
+	ErrKeyNotFound = &Error{
+		Code:    http.StatusNotFound,
+		Key:     "key_not_found",
+		Message: "API key not found",
+	}
+	// ErrTenantNotFound HTTP 404
+	ErrTenantNotFound = &Error{
+		Code:    http.StatusNotFound,
+		Key:     "tenant_not_found",
+		Message: "Library not found",
+	}
+	// ErrTenantExists HTTP 409
+	ErrTenantExists = &Error{
+		Code:    http.StatusConflict,
+		Key:     "tenant_exists",
+		Message: "A library with this id already exists",
+	}
+	// ErrInvalidTenantID HTTP 400
+	ErrInvalidTenantID = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_tenant_id",
+		Message: "Library id should be 1-63 lowercase letters, digits and hyphens",
+	}
+	// ErrTenantNameIsRequired HTTP 400
+	ErrTenantNameIsRequired = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "tenant_name_required",
+		Message: "A library name is required",
+	}
+	// ErrInvalidLoanPeriod HTTP 400
+	ErrInvalidLoanPeriod = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_loan_period",
+		Message: "Loan period must be 1-365 days",
+	}
+	// ErrInvalidRatingScale HTTP 400
+	ErrInvalidRatingScale = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_rating_scale",
+		Message: "Highest rating must be 1-10",
+	}
+	// ErrBranchNotFound HTTP 404
+	ErrBranchNotFound = &Error{
+		Code:    http.StatusNotFound,
+		Key:     "branch_not_found",
+		Message: "Branch not found",
+	}
+	// ErrLocationNotFound HTTP 404
+	ErrLocationNotFound = &Error{
+		Code:    http.StatusNotFound,
+		Key:     "location_not_found",
+		Message: "Location not found",
+	}
+	// ErrTransferNotFound HTTP 404
+	ErrTransferNotFound = &Error{
+		Code:    http.StatusNotFound,
+		Key:     "transfer_not_found",
+		Message: "Transfer not found",
+	}
+	// ErrBranchNameIsRequired HTTP 400
+	ErrBranchNameIsRequired = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "branch_name_required",
+		Message: "A branch name is required",
+	}
+	// ErrLocationIsRequired HTTP 422
+	ErrLocationIsRequired = &Error{
+		Code:    http.StatusUnprocessableEntity,
+		Key:     "location_required",
+		Message: "A location is required",
+	}
+	// ErrBranchIsRequired HTTP 422
+	ErrBranchIsRequired = &Error{
+		Code:    http.StatusUnprocessableEntity,
+		Key:     "branch_required",
+		Message: "A branch to send the book to is required",
+	}
+	// ErrShelfIsRequired HTTP 400
+	ErrShelfIsRequired = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "shelf_required",
+		Message: "A shelf is required",
+	}
+	// ErrInvalidTransferStatus HTTP 400
+	ErrInvalidTransferStatus = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_transfer_status",
+		Message: "Transfer status should be in_transit or received",
+	}
+	// ErrLocationNotInBranch HTTP 400
+	ErrLocationNotInBranch = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "location_not_in_branch",
+		Message: "The location is not in the branch the book is sent to",
+	}
+	// ErrSameBranch HTTP 400
+	ErrSameBranch = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "same_branch",
+		Message: "The book is already at this branch",
+	}
+	// ErrBookInTransit HTTP 409
+	ErrBookInTransit = &Error{
+		Code:    http.StatusConflict,
+		Key:     "book_in_transit",
+		Message: "The book is in transit, it must be received first",
+	}
+	// ErrTransferReceived HTTP 409
+	ErrTransferReceived = &Error{
+		Code:    http.StatusConflict,
+		Key:     "transfer_received",
+		Message: "The transfer has already been received",
+	}
+	// ErrInvalidCallNumber HTTP 400
+	ErrInvalidCallNumber = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_call_number",
+		Message: "Call number should be a Dewey Decimal or Library of Congress call number",
+	}
+	// ErrInvalidSort HTTP 400
+	ErrInvalidSort = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_sort",
+		Message: "Sort should be id or shelf",
+	}
+	// ErrTooManyRequests HTTP 429
+	ErrTooManyRequests = &Error{
+		Code:    http.StatusTooManyRequests,
+		Key:     "too_many_requests",
+		Message: "Too many requests, retry later",
+	}
+	// ErrInvalidIdempotencyKey HTTP 400
+	ErrInvalidIdempotencyKey = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_idempotency_key",
+		Message: "Idempotency-Key should be at most 255 characters",
+	}
+	// ErrIdempotencyKeyReused HTTP 422
+	ErrIdempotencyKeyReused = &Error{
+		Code:    http.StatusUnprocessableEntity,
+		Key:     "idempotency_key_reused",
+		Message: "Idempotency-Key was already used for a different request",
+	}
+	// ErrIdempotencyKeyInUse HTTP 409
+	ErrIdempotencyKeyInUse = &Error{
+		Code:    http.StatusConflict,
+		Key:     "idempotency_key_in_use",
+		Message: "A request with this Idempotency-Key is in progress, retry later",
+	}
+	// ErrInvalidPatch HTTP 400
+	ErrInvalidPatch = &Error{
+		Code:    http.StatusBadRequest,
+		Key:     "invalid_patch",
+		Message: "Patch document is invalid",
+	}
+	// ErrPatchTestFailed HTTP 409
+	ErrPatchTestFailed = &Error{
+		Code:    http.StatusConflict,
+		Key:     "patch_test_failed",
+		Message: "Patch test operation failed",
+	}
+	// ErrPreconditionFailed HTTP 412
+	ErrPreconditionFailed = &Error{
+		Code:    http.StatusPreconditionFailed,
+		Key:     "precondition_failed",
+		Message: "Book has been modified, fetch it again and retry",
+	}
+	// ErrPreconditionRequired HTTP 428
+	ErrPreconditionRequired = &Error{
+		Code:    http.StatusPreconditionRequired,
+		Key:     "precondition_required",
+		Message: "If-Match is required, send the ETag of the book",
+	}
+	// ErrUnsupportedMARCMediaType HTTP 415
+	ErrUnsupportedMARCMediaType = &Error{
+		Code:    http.StatusUnsupportedMediaType,
+		Key:     "unsupported_marc_media_type",
+		Message: "Content-Type should be application/marc or application/marcxml+xml",
+	}
+	// ErrNotAcceptable HTTP 406
+	ErrNotAcceptable = &Error{
+		Code:    http.StatusNotAcceptable,
+		Key:     "not_acceptable",
+		Message: "Accept should be application/json, application/xml, application/yaml or application/x-ndjson",
+	}
+	// ErrUnsupportedONIXMediaType HTTP 415
+	ErrUnsupportedONIXMediaType = &Error{
+		Code:    http.StatusUnsupportedMediaType,
+		Key:     "unsupported_onix_media_type",
+		Message: "Content-Type should be application/xml, or multipart/form-data with the feed as file",
+	}
+	// ErrUnsupportedMediaType HTTP 415
+	ErrUnsupportedMediaType = &Error{
+		Code:    http.StatusUnsupportedMediaType,
+		Key:     "unsupported_patch_media_type",
+		Message: "Content-Type should be application/merge-patch+json or application/json-patch+json",
+	}
 )
 
+// ProblemContentType media type of errors, written as RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
 // Error main object for error
 type Error struct {
-	Code    int
+	Code int
+	// Key stable machine-readable code of the error, e.g book_not_found
+	Key string
+	// Message English message of the error, see Localize for other languages
 	Message string
+	// Instance URI of the request the error occurred on
+	Instance string
+	// Fields errors of each invalid field of the request
+	Fields []*FieldError
+}
+
+// FieldError error of a single field of a request
+type FieldError struct {
+	Field string
+	Err   *Error
+}
+
+// problem RFC 7807 problem details of an Error
+type problem struct {
+	Type     string          `json:"type"`
+	Title    string          `json:"title"`
+	Status   int             `json:"status"`
+	Code     string          `json:"code,omitempty"`
+	Detail   string          `json:"detail,omitempty"`
+	Instance string          `json:"instance,omitempty"`
+	Errors   []*fieldProblem `json:"errors,omitempty"`
+}
+
+type fieldProblem struct {
+	Field  string `json:"field"`
+	Code   string `json:"code,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// Invalid error of the invalid fields of a request, nil when there are none. Fields failing
+// with the same error are reported with it, others with ErrInvalidFields.
+func Invalid(fields ...*FieldError) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	res := *fields[0].Err
+	for _, f := range fields[1:] {
+		if f.Err != fields[0].Err {
+			res = *ErrInvalidFields
+			break
+		}
+	}
+	res.Fields = fields
+	return &res
+}
+
+// WithInstance copy of the error occurring on the given request URI
+func (err *Error) WithInstance(uri string) *Error {
+	res := *err
+	res.Instance = uri
+	return &res
+}
+
+// MarshalJSON writes the error as RFC 7807 problem details
+func (err *Error) MarshalJSON() ([]byte, error) {
+	p := &problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(err.Code),
+		Status:   err.Code,
+		Code:     err.Key,
+		Detail:   err.Message,
+		Instance: err.Instance,
+	}
+	for _, f := range err.Fields {
+		p.Errors = append(p.Errors, &fieldProblem{Field: f.Field, Code: f.Err.Key, Detail: f.Err.Message})
+	}
+	return json.Marshal(p)
+}
+
+// UnmarshalJSON reads an error from RFC 7807 problem details
+func (err *Error) UnmarshalJSON(data []byte) error {
+	p := &problem{}
+	if e := json.Unmarshal(data, p); e != nil {
+		return e
+	}
+	err.Code, err.Key, err.Message, err.Instance, err.Fields = p.Status, p.Code, p.Detail, p.Instance, nil
+	for _, f := range p.Errors {
+		err.Fields = append(err.Fields, &FieldError{Field: f.Field, Err: &Error{Code: p.Status, Key: f.Code, Message: f.Detail}})
+	}
+	return nil
 }
 
 func (err *Error) Error() string {
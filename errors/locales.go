@@ -0,0 +1,95 @@
+package errors
+
+import (
+	"embed"
+	"encoding/json"
+	"mime"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage language of the messages declared with the errors
+const DefaultLanguage = "en"
+
+// locales message catalogs of the other languages, one JSON file per language mapping
+// the keys of the errors to their messages
+//
+//go:embed locales/*.json
+var locales embed.FS
+
+// catalogs messages by key, of each language
+var catalogs = map[string]map[string]string{}
+
+func init() {
+	files, err := locales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, f := range files {
+		data, err := locales.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+		catalog := map[string]string{}
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic("errors: " + f.Name() + ": " + err.Error())
+		}
+		catalogs[strings.TrimSuffix(f.Name(), ".json")] = catalog
+	}
+}
+
+// Language best supported language of an Accept-Language header, DefaultLanguage when none is
+func Language(acceptLanguage string) string {
+	type accepted struct {
+		tag string
+		q   float64
+	}
+	var ranges []accepted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		// language ranges have the same parameters as media types, e.g es-MX;q=0.8
+		tag, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, accepted{tag, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	for _, a := range ranges {
+		// only the primary language is matched, e.g es for es-MX
+		lang := strings.SplitN(a.tag, "-", 2)[0]
+		if _, ok := catalogs[lang]; ok || lang == DefaultLanguage {
+			return lang
+		}
+	}
+	return DefaultLanguage
+}
+
+// Localize copy of the error with its messages in the given language, those missing from its
+// catalog are kept in English
+func (err *Error) Localize(lang string) *Error {
+	catalog, ok := catalogs[lang]
+	if !ok {
+		return err
+	}
+	res := *err
+	if msg, ok := catalog[err.Key]; ok {
+		res.Message = msg
+	}
+	if len(err.Fields) > 0 {
+		res.Fields = make([]*FieldError, len(err.Fields))
+		for i, f := range err.Fields {
+			res.Fields[i] = &FieldError{Field: f.Field, Err: f.Err.Localize(lang)}
+		}
+	}
+	return &res
+}
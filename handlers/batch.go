@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+	"github.com/redeam/gobooks/store"
+)
+
+func (h *handler) Batch(w http.ResponseWriter, r *http.Request) {
+	h.idempotent(w, r, h.batch)
+}
+
+func (h *handler) batch(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, errors.ErrUnprocessableEntity)
+		return
+	}
+	req := &objects.BatchRequest{}
+	if Unmarshal(w, data, req) != nil {
+		return
+	}
+	if err = objects.Validate(req); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	// validate every operation up front, so all the errors are reported at once
+	results := make([]*objects.BatchResult, len(req.Operations))
+	valid := true
+	for i, op := range req.Operations {
+		if op == nil {
+			results[i] = &objects.BatchResult{}
+			setResultError(results[i], errors.ErrObjectIsRequired)
+			valid = false
+			continue
+		}
+		results[i] = &objects.BatchResult{Op: op.Op, ID: op.ID}
+		err := validateBatchOperation(r.Context(), op)
+		if err == nil && h.cfg.RequireIfMatch && op.Op != objects.OpCreate && op.Version == 0 {
+			// the version is the If-Match of an operation
+			err = errors.ErrPreconditionRequired
+		}
+		if err != nil {
+			setResultError(results[i], err)
+			valid = false
+		}
+	}
+
+	res := &objects.BookResponseWrapper{Results: results}
+	if req.Mode == objects.BestEffort {
+		for i, op := range req.Operations {
+			if results[i].Error == "" {
+				applyBatchOperation(r.Context(), h.store, op, results[i])
+			}
+		}
+		writeResults(w, res)
+		return
+	}
+
+	err = errors.ErrBadRequest
+	if valid {
+		err = h.store.Transaction(r.Context(), func(st store.IBookStore) error {
+			for i, op := range req.Operations {
+				if err := applyBatchOperation(r.Context(), st, op, results[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		// nothing was applied, report the failed operations and mark the others as aborted
+		for i, result := range results {
+			if result.Error != "" {
+				if res.Code == 0 {
+					res.Code = result.Status
+				}
+				continue
+			}
+			result.ID, result.Book = req.Operations[i].ID, nil
+			setResultError(result, errors.ErrBatchAborted)
+		}
+		if res.Code == 0 {
+			// the commit itself failed
+			log.Println(err)
+			res.Code = errors.ErrInternal.Code
+		}
+	}
+	writeResults(w, res)
+}
+
+// validateBatchOperation checks a batch operation with the same rules as the single book handlers
+func validateBatchOperation(ctx context.Context, op *objects.BatchOperation) error {
+	if err := objects.Validate(op); err != nil {
+		return err
+	}
+	switch op.Op {
+	case objects.OpCreate:
+		if op.Book == nil {
+			return errors.ErrObjectIsRequired
+		}
+		return objects.Validate(op.Book, objects.TenantFromContext(ctx).BookRules()...)
+	case objects.OpUpdate:
+		if op.Book == nil {
+			return errors.ErrObjectIsRequired
+		}
+		req := updateDetailsRequest(op)
+		if err := objects.Validate(req, objects.TenantFromContext(ctx).BookRules()...); err != nil {
+			return err
+		}
+		op.Book.ISBN, op.Book.CallNumber = req.ISBN, req.CallNumber
+		return nil
+	}
+	if op.ID == "" {
+		return errors.ErrValidBookIdIsRequired
+	}
+	return nil
+}
+
+// applyBatchOperation applies a validated batch operation to the store, recording its outcome
+func applyBatchOperation(ctx context.Context, st store.IBookStore, op *objects.BatchOperation, result *objects.BatchResult) error {
+	var err error
+	switch op.Op {
+	case objects.OpCreate:
+		if err = st.Create(ctx, &objects.CreateRequest{Book: op.Book}); err == nil {
+			result.ID, result.Book = op.Book.ID, op.Book
+		}
+	case objects.OpUpdate:
+		if err = st.UpdateDetails(ctx, updateDetailsRequest(op)); err == nil {
+			result.Book, err = st.Get(ctx, &objects.GetRequest{ID: op.ID})
+		}
+	case objects.OpDelete:
+		err = st.Delete(ctx, &objects.DeleteRequest{ID: op.ID, Version: op.Version})
+	}
+	if err != nil {
+		setResultError(result, err)
+		return err
+	}
+	result.Status = http.StatusOK
+	return nil
+}
+
+func updateDetailsRequest(op *objects.BatchOperation) *objects.UpdateDetailsRequest {
+	return &objects.UpdateDetailsRequest{
+		ID:          op.ID,
+		ISBN:        op.Book.ISBN,
+		Title:       op.Book.Title,
+		Author:      op.Book.Author,
+		Publisher:   op.Book.Publisher,
+		PublishDate: op.Book.PublishDate,
+		Status:      op.Book.Status,
+		Rating:      op.Book.Rating,
+		CallNumber:  op.Book.CallNumber,
+		Version:     op.Version,
+	}
+}
+
+func setResultError(result *objects.BatchResult, err error) {
+	e, ok := err.(*errors.Error)
+	if !ok {
+		log.Println(err)
+		e = errors.ErrInternal
+	}
+	result.SetError(e)
+}
+
+// writeResults writes the results of a batch or an import, with their errors in the language
+// negotiated for the response
+func writeResults(w http.ResponseWriter, res *objects.BookResponseWrapper) {
+	if ew, ok := w.(*encodingWriter); ok {
+		for _, result := range res.Results {
+			if result.Err != nil {
+				result.SetError(result.Err.Localize(ew.lang))
+				w.Header().Set("Content-Language", ew.lang)
+			}
+		}
+	}
+	WriteResponse(w, res)
+}
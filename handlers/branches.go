@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+)
+
+func (h *handler) CreateBranch(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, errors.ErrUnprocessableEntity)
+		return
+	}
+	b := &objects.Branch{}
+	if Unmarshal(w, data, b) != nil {
+		return
+	}
+	if err = objects.Validate(b); err != nil {
+		WriteError(w, err)
+		return
+	}
+	if err = h.store.CreateBranch(r.Context(), b); err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.BranchResponseWrapper{Branch: b})
+}
+
+func (h *handler) ListBranches(w http.ResponseWriter, r *http.Request) {
+	list, err := h.store.ListBranches(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.BranchResponseWrapper{Branches: list})
+}
+
+func (h *handler) CreateLocation(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, errors.ErrUnprocessableEntity)
+		return
+	}
+	l := &objects.Location{}
+	if Unmarshal(w, data, l) != nil {
+		return
+	}
+	l.BranchID = mux.Vars(r)["id"]
+	if err = objects.Validate(l); err != nil {
+		WriteError(w, err)
+		return
+	}
+	if err = h.store.CreateLocation(r.Context(), l); err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.BranchResponseWrapper{Location: l})
+}
+
+func (h *handler) ListLocations(w http.ResponseWriter, r *http.Request) {
+	list, err := h.store.ListLocations(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.BranchResponseWrapper{Locations: list})
+}
+
+func (h *handler) ShelfList(w http.ResponseWriter, r *http.Request) {
+	loc, list, err := h.store.ShelfList(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.BranchResponseWrapper{Location: loc, Books: list})
+}
+
+func (h *handler) Move(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, errors.ErrUnprocessableEntity)
+		return
+	}
+	req := &objects.MoveRequest{}
+	if Unmarshal(w, data, req) != nil {
+		return
+	}
+	req.ID = mux.Vars(r)["id"]
+	if err = objects.Validate(req); err != nil {
+		WriteError(w, err)
+		return
+	}
+	// check if book exists.
+	cur, err := h.store.Get(r.Context(), &objects.GetRequest{ID: req.ID})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if req.Version, err = h.checkIfMatch(r, cur); err != nil {
+		WriteError(w, err)
+		return
+	}
+	bk, err := h.store.Move(r.Context(), req)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.Header().Set("ETag", ETag(bk.Version))
+	WriteResponse(w, &objects.BookResponseWrapper{Book: bk})
+}
+
+func (h *handler) Transfer(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, errors.ErrUnprocessableEntity)
+		return
+	}
+	req := &objects.TransferRequest{}
+	if Unmarshal(w, data, req) != nil {
+		return
+	}
+	req.ID = mux.Vars(r)["id"]
+	if err = objects.Validate(req); err != nil {
+		WriteError(w, err)
+		return
+	}
+	// check if book exists.
+	cur, err := h.store.Get(r.Context(), &objects.GetRequest{ID: req.ID})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if req.Version, err = h.checkIfMatch(r, cur); err != nil {
+		WriteError(w, err)
+		return
+	}
+	tr, err := h.store.Transfer(r.Context(), req)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.BranchResponseWrapper{Transfer: tr})
+}
+
+func (h *handler) ListTransfers(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	req := &objects.ListTransfersRequest{
+		Status:   values.Get("status"),
+		BranchID: values.Get("branch"),
+	}
+	switch req.Status {
+	case "", string(objects.TransferInTransit), string(objects.TransferReceived):
+	default:
+		WriteError(w, errors.ErrInvalidTransferStatus)
+		return
+	}
+	list, err := h.store.ListTransfers(r.Context(), req)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.BranchResponseWrapper{Transfers: list})
+}
+
+func (h *handler) Receive(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, errors.ErrUnprocessableEntity)
+		return
+	}
+	req := &objects.ReceiveRequest{}
+	// the location is optional, and so is the body
+	if len(data) > 0 && Unmarshal(w, data, req) != nil {
+		return
+	}
+	req.ID = mux.Vars(r)["id"]
+	tr, err := h.store.Receive(r.Context(), req)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.BranchResponseWrapper{Transfer: tr})
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/redeam/gobooks/citation"
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+)
+
+// citationFormat citation format asked with the format parameter, nil when the books are
+// written with the encoder negotiated from the Accept header
+func citationFormat(r *http.Request) (*citation.Format, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		if name == "json" {
+			return nil, nil
+		}
+		if f := citation.ByName(name); f != nil {
+			return f, nil
+		}
+		return nil, errors.ErrInvalidCitationFormat
+	}
+	return nil, nil
+}
+
+// writeCitations writes the books as citations in the given format
+func writeCitations(w http.ResponseWriter, f *citation.Format, books ...*objects.Book) {
+	w.Header().Set("Content-Type", f.ContentType)
+	if err := f.Write(w, books...); err != nil {
+		log.Println(err)
+	}
+}
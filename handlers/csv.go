@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+)
+
+func (h *handler) Export(w http.ResponseWriter, r *http.Request) {
+	cw := csv.NewWriter(w)
+	_ = h.exportPages(w, r, func() {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="books.csv"`)
+		_ = cw.Write(objects.CSVColumns)
+	}, func(list []*objects.Book) error {
+		for _, bk := range list {
+			_ = cw.Write(bk.CSVRecord())
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+}
+
+func (h *handler) Import(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseImportOptions(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	cr := csv.NewReader(r.Body)
+	header, err := cr.Read()
+	if err == io.EOF {
+		WriteError(w, errors.ErrObjectIsRequired)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		WriteError(w, errors.ErrInvalidCSV)
+		return
+	}
+	for _, column := range header {
+		if !(&objects.Book{}).SetCSVField(column, "") {
+			WriteError(w, errors.ErrUnknownCSVColumn)
+			return
+		}
+	}
+	var (
+		books   []*importedBook
+		results []*objects.BatchResult
+	)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Println(err)
+			WriteError(w, errors.ErrInvalidCSV)
+			return
+		}
+		bk, cells := &objects.Book{}, map[string]string{}
+		for i, column := range header {
+			bk.SetCSVField(column, record[i])
+			cells[column] = record[i]
+		}
+		// the header is the first line
+		books = append(books, &importedBook{Book: bk, cells: cells})
+		results = append(results, &objects.BatchResult{Row: len(books) + 1})
+	}
+	h.importBooks(w, r, opts, books, results)
+}
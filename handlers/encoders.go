@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/redeam/gobooks/citation"
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/marc"
+	"github.com/redeam/gobooks/objects"
+)
+
+// Encoder writes responses in a media type
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, res Response) error
+}
+
+// errNotEncodable returned by encoders that can't write a response, which is then written as JSON
+var errNotEncodable = fmt.Errorf("response can't be encoded in the negotiated media type")
+
+// registered encoders by media type, in order of preference for wildcards
+var (
+	encoderTypes []string
+	encoders     = map[string]Encoder{}
+)
+
+// RegisterEncoder registers the encoder of a media type, replacing the one registered before
+func RegisterEncoder(mediaType string, enc Encoder) {
+	if _, ok := encoders[mediaType]; !ok {
+		encoderTypes = append(encoderTypes, mediaType)
+	}
+	encoders[mediaType] = enc
+}
+
+func init() {
+	RegisterEncoder("application/json", jsonEncoder{})
+	RegisterEncoder("application/xml", xmlEncoder{"application/xml"})
+	RegisterEncoder("text/xml", xmlEncoder{"text/xml"})
+	RegisterEncoder("application/yaml", yamlEncoder{"application/yaml"})
+	RegisterEncoder("application/x-yaml", yamlEncoder{"application/x-yaml"})
+	RegisterEncoder("text/yaml", yamlEncoder{"text/yaml"})
+	RegisterEncoder("application/x-ndjson", ndjsonEncoder{})
+	for _, f := range []*citation.Format{citation.BibTeX, citation.RIS, citation.CSLJSON} {
+		RegisterEncoder(f.ContentType, &bookEncoder{contentType: f.ContentType, write: f.Write})
+	}
+	RegisterEncoder("text/csv", &bookEncoder{contentType: "text/csv", write: writeCSV})
+	RegisterEncoder(marc.BinaryContentType, &bookEncoder{contentType: marc.BinaryContentType, write: writeMARC})
+	RegisterEncoder(marc.XMLContentType, &bookEncoder{contentType: marc.XMLContentType, write: writeMARCXML})
+}
+
+// NegotiateEncoder best registered encoder for an Accept header, JSON when the header is empty
+func NegotiateEncoder(accept string) (Encoder, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return jsonEncoder{}, true
+	}
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []accepted
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, accepted{mediaType, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	for _, a := range ranges {
+		if enc, ok := encoders[a.mediaType]; ok {
+			return enc, true
+		}
+		// wildcards, e.g */* or application/*
+		if !strings.HasSuffix(a.mediaType, "/*") {
+			continue
+		}
+		prefix := strings.TrimSuffix(a.mediaType, "*")
+		if prefix == "*/" {
+			prefix = ""
+		}
+		for _, mediaType := range encoderTypes {
+			if strings.HasPrefix(mediaType, prefix) {
+				return encoders[mediaType], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Negotiate middleware choosing the encoder of the responses from the Accept header, and
+// the language of errors from the Accept-Language header, answering 406 Not Acceptable
+// when no registered encoder matches
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept, Accept-Language")
+		ew := &encodingWriter{
+			ResponseWriter: w,
+			enc:            jsonEncoder{},
+			instance:       r.URL.RequestURI(),
+			lang:           errors.Language(r.Header.Get("Accept-Language")),
+		}
+		enc, ok := NegotiateEncoder(r.Header.Get("Accept"))
+		if !ok {
+			WriteError(ew, errors.ErrNotAcceptable)
+			return
+		}
+		ew.enc = enc
+		w.Header().Set("Content-Type", enc.ContentType())
+		next.ServeHTTP(ew, r)
+	})
+}
+
+// encodingWriter response writer carrying the negotiated encoder and language, and the
+// request URI reported as the instance of errors
+type encodingWriter struct {
+	http.ResponseWriter
+	enc      Encoder
+	instance string
+	lang     string
+}
+
+// Flush flushes the underlying writer, for the streamed responses
+func (w *encodingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// encoderOf negotiated encoder of a response writer, JSON when there is none
+func encoderOf(w http.ResponseWriter) Encoder {
+	if ew, ok := w.(*encodingWriter); ok {
+		return ew.enc
+	}
+	return jsonEncoder{}
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string { return "application/json" }
+
+func (jsonEncoder) Encode(w io.Writer, res Response) error {
+	_, err := w.Write(res.JSON())
+	return err
+}
+
+// ndjsonEncoder writes each item of a list on its own line, other responses on a single line
+type ndjsonEncoder struct{}
+
+func (ndjsonEncoder) ContentType() string { return "application/x-ndjson" }
+
+func (ndjsonEncoder) Encode(w io.Writer, res Response) error {
+	data := res.JSON()
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err == nil {
+		for _, key := range []string{"books", "history", "results"} {
+			var items []json.RawMessage
+			if json.Unmarshal(fields[key], &items) != nil || items == nil {
+				continue
+			}
+			for _, item := range items {
+				if _, err := fmt.Fprintf(w, "%s\n", item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// xmlEncoder writes the JSON document of a response as XML, in a response element
+// with the same field names, and an item element for each item of a list
+type xmlEncoder struct {
+	contentType string
+}
+
+func (e xmlEncoder) ContentType() string { return e.contentType }
+
+func (xmlEncoder) Encode(w io.Writer, res Response) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(res.JSON()))
+	dec.UseNumber()
+	enc := xml.NewEncoder(w)
+	if err := writeXMLValue(dec, enc, "response"); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func writeXMLValue(dec *json.Decoder, enc *xml.Encoder, name string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if tok == nil {
+		// null values are left out
+		return nil
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		for dec.More() {
+			child := "item"
+			if v == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				child = key.(string)
+			}
+			if err := writeXMLValue(dec, enc, child); err != nil {
+				return err
+			}
+		}
+		// closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// yamlEncoder writes the JSON document of a response as YAML, keeping its field order
+type yamlEncoder struct {
+	contentType string
+}
+
+func (e yamlEncoder) ContentType() string { return e.contentType }
+
+func (yamlEncoder) Encode(w io.Writer, res Response) error {
+	// JSON documents are YAML flow documents, they are written back in block style
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(res.JSON(), doc); err != nil {
+		return err
+	}
+	blockStyle(doc)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+// bookEncoder writes the books of a response in a book format, such as a citation format
+type bookEncoder struct {
+	contentType string
+	write       func(w io.Writer, books ...*objects.Book) error
+}
+
+func (e *bookEncoder) ContentType() string { return e.contentType }
+
+func (e *bookEncoder) Encode(w io.Writer, res Response) error {
+	wrapper, ok := res.(*objects.BookResponseWrapper)
+	if !ok || wrapper == nil || (wrapper.Book == nil && wrapper.Books == nil) {
+		return errNotEncodable
+	}
+	if wrapper.Book != nil {
+		return e.write(w, wrapper.Book)
+	}
+	return e.write(w, wrapper.Books...)
+}
+
+func writeCSV(w io.Writer, books ...*objects.Book) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write(objects.CSVColumns)
+	for _, bk := range books {
+		_ = cw.Write(bk.CSVRecord())
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeMARC(w io.Writer, books ...*objects.Book) error {
+	return marc.WriteBinary(w, marcRecords(books)...)
+}
+
+func writeMARCXML(w io.Writer, books ...*objects.Book) error {
+	return marc.WriteXML(w, marcRecords(books)...)
+}
+
+func marcRecords(books []*objects.Book) []*marc.Record {
+	records := make([]*marc.Record, len(books))
+	for i, bk := range books {
+		records[i] = marc.FromBook(bk)
+	}
+	return records
+}
@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"encoding/json"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/redeam/gobooks/errors"
 	"github.com/redeam/gobooks/objects"
 	"github.com/redeam/gobooks/store"
@@ -13,18 +18,51 @@ import (
 type IBookHandler interface {
 	Get(w http.ResponseWriter, r *http.Request)
 	List(w http.ResponseWriter, r *http.Request)
+	Stream(w http.ResponseWriter, r *http.Request)
 	Create(w http.ResponseWriter, r *http.Request)
 	UpdateDetails(w http.ResponseWriter, r *http.Request)
+	Patch(w http.ResponseWriter, r *http.Request)
 	Delete(w http.ResponseWriter, r *http.Request)
+	Trash(w http.ResponseWriter, r *http.Request)
+	Restore(w http.ResponseWriter, r *http.Request)
+	Purge(w http.ResponseWriter, r *http.Request)
+	History(w http.ResponseWriter, r *http.Request)
+	Revert(w http.ResponseWriter, r *http.Request)
+	Batch(w http.ResponseWriter, r *http.Request)
+	Export(w http.ResponseWriter, r *http.Request)
+	Import(w http.ResponseWriter, r *http.Request)
+	ExportMARC(w http.ResponseWriter, r *http.Request)
+	ImportMARC(w http.ResponseWriter, r *http.Request)
+	ImportONIX(w http.ResponseWriter, r *http.Request)
+	CreateBranch(w http.ResponseWriter, r *http.Request)
+	ListBranches(w http.ResponseWriter, r *http.Request)
+	CreateLocation(w http.ResponseWriter, r *http.Request)
+	ListLocations(w http.ResponseWriter, r *http.Request)
+	ShelfList(w http.ResponseWriter, r *http.Request)
+	Move(w http.ResponseWriter, r *http.Request)
+	Transfer(w http.ResponseWriter, r *http.Request)
+	ListTransfers(w http.ResponseWriter, r *http.Request)
+	Receive(w http.ResponseWriter, r *http.Request)
+}
+
+// BookHandlerConfig options of the book handlers
+type BookHandlerConfig struct {
+	// RequireIfMatch refuses the changes of a book made without an If-Match, so clients can't
+	// overwrite changes they haven't seen
+	RequireIfMatch bool
+	// TrustProxy takes the address of anonymous clients from the X-Forwarded-For header, as
+	// RateLimitConfig does, so their Idempotency-Keys are their own
+	TrustProxy bool
 }
 
 type handler struct {
 	store store.IBookStore
+	cfg   BookHandlerConfig
 }
 
 // NewBookHandler return current IBookHandler implementation
-func NewBookHandler(store store.IBookStore) IBookHandler {
-	return &handler{store: store}
+func NewBookHandler(store store.IBookStore, cfg BookHandlerConfig) IBookHandler {
+	return &handler{store: store, cfg: cfg}
 }
 
 func (h *handler) Get(w http.ResponseWriter, r *http.Request) {
@@ -34,11 +72,25 @@ func (h *handler) Get(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, errors.ErrValidBookIdIsRequired)
 		return
 	}
+	format, err := citationFormat(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
 	bk, err := h.store.Get(r.Context(), &objects.GetRequest{ID: id})
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
+	w.Header().Set("ETag", ETag(bk.Version))
+	if inm := r.Header.Get("If-None-Match"); inm != "" && MatchETag(inm, bk.Version, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if format != nil {
+		writeCitations(w, format, bk)
+		return
+	}
 	WriteResponse(w, &objects.BookResponseWrapper{Book: bk})
 }
 
@@ -51,19 +103,40 @@ func (h *handler) List(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		return
 	}
+	sort, err := SortFromString(w, values.Get("sort"))
+	if err != nil {
+		return
+	}
+	format, err := citationFormat(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
 	// list books
 	list, err := h.store.List(r.Context(), &objects.ListRequest{
-		Limit: limit,
-		Title: title,
+		Limit:           limit,
+		Title:           title,
+		ISBN:            values.Get("isbn"),
+		RecordReference: values.Get("record_reference"),
+		Branch:          values.Get("branch"),
+		Sort:            sort,
 	})
 	if err != nil {
 		WriteError(w, err)
 		return
 	}
+	if format != nil {
+		writeCitations(w, format, list...)
+		return
+	}
 	WriteResponse(w, &objects.BookResponseWrapper{Books: list})
 }
 
 func (h *handler) Create(w http.ResponseWriter, r *http.Request) {
+	h.idempotent(w, r, h.create)
+}
+
+func (h *handler) create(w http.ResponseWriter, r *http.Request) {
 	data, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		WriteError(w, errors.ErrUnprocessableEntity)
@@ -73,29 +146,15 @@ func (h *handler) Create(w http.ResponseWriter, r *http.Request) {
 	if Unmarshal(w, data, bk) != nil {
 		return
 	}
-	//Make sure we have a title and author
-	if bk.Title == "" || bk.Author == "" {
-		WriteError(w, errors.ErrTitleandAuthorIsRequired)
-		return
-	}
-	//Check the status if we have an appropriate status - set to CheckedIn if empty, return error if a non-acceptable status is submitted
-	if bk.Status != "CheckedIn" && bk.Status != "CheckedOut" {
-		if bk.Status == "" {
-			bk.Status = "CheckedIn"
-		} else {
-			WriteError(w, errors.ErrStatusIsRequired)
-			return
-		}
-	}
-	//Check that rating is supplied
-	if bk.Rating > 3 || bk.Rating < 1 {
-		WriteError(w, errors.ErrRatingIsRequired)
+	if err = objects.Validate(bk, objects.TenantFromContext(r.Context()).BookRules()...); err != nil {
+		WriteError(w, err)
 		return
 	}
 	if err = h.store.Create(r.Context(), &objects.CreateRequest{Book: bk}); err != nil {
 		WriteError(w, err)
 		return
 	}
+	w.Header().Set("ETag", ETag(bk.Version))
 	WriteResponse(w, &objects.BookResponseWrapper{Book: bk})
 }
 
@@ -109,18 +168,17 @@ func (h *handler) UpdateDetails(w http.ResponseWriter, r *http.Request) {
 	if Unmarshal(w, data, req) != nil {
 		return
 	}
-	//Check if ID is supplied
-	if req.ID == "" {
-		WriteError(w, errors.ErrValidBookIdIsRequired)
+	if err = objects.Validate(req, objects.TenantFromContext(r.Context()).BookRules()...); err != nil {
+		WriteError(w, err)
 		return
 	}
-	//Check the status
-	if req.Status != "CheckedIn" && req.Status != "CheckedOut" && len(req.Status) > 0 {
-		WriteError(w, errors.ErrStatusIsRequired)
+	//check if book exists.
+	cur, err := h.store.Get(r.Context(), &objects.GetRequest{ID: req.ID})
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
-	//check if book exists.
-	if _, err := h.store.Get(r.Context(), &objects.GetRequest{ID: req.ID}); err != nil {
+	if req.Version, err = h.checkIfMatch(r, cur); err != nil {
 		WriteError(w, err)
 		return
 	}
@@ -137,6 +195,68 @@ func (h *handler) UpdateDetails(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, err)
 		return
 	}
+	w.Header().Set("ETag", ETag(bk.Version))
+	WriteResponse(w, &objects.BookResponseWrapper{Book: bk})
+}
+
+func (h *handler) Patch(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var apply func(doc, patch []byte) ([]byte, error)
+	switch mediaType {
+	case objects.MergePatchContentType:
+		apply = MergePatch
+	case objects.JSONPatchContentType:
+		apply = JSONPatch
+	default:
+		WriteError(w, errors.ErrUnsupportedMediaType)
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, errors.ErrUnprocessableEntity)
+		return
+	}
+	if d := string(data); d == "null" || d == "" {
+		WriteError(w, errors.ErrObjectIsRequired)
+		return
+	}
+	//check if book exists.
+	old, err := h.store.Get(r.Context(), &objects.GetRequest{ID: id})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if _, err = h.checkIfMatch(r, old); err != nil {
+		WriteError(w, err)
+		return
+	}
+	doc, err := json.Marshal(bookDocument(old))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if doc, err = apply(doc, data); err != nil {
+		WriteError(w, err)
+		return
+	}
+	bk := &objects.Book{}
+	if err = json.Unmarshal(doc, bk); err != nil {
+		WriteError(w, errors.ErrInvalidPatch)
+		return
+	}
+	// identifier and meta information can't be patched,
+	// the update only applies to the version the patch was computed from
+	bk.ID, bk.CreatedOn, bk.UpdatedOn, bk.Version = old.ID, old.CreatedOn, old.UpdatedOn, old.Version
+	if err = objects.Validate(bk, objects.TenantFromContext(r.Context()).BookRules()...); err != nil {
+		WriteError(w, err)
+		return
+	}
+	if err = h.store.Update(r.Context(), &objects.UpdateRequest{Book: bk}); err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.Header().Set("ETag", ETag(bk.Version))
 	WriteResponse(w, &objects.BookResponseWrapper{Book: bk})
 }
 
@@ -148,14 +268,166 @@ func (h *handler) Delete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// check if book exist
-	if _, err := h.store.Get(r.Context(), &objects.GetRequest{ID: id}); err != nil {
+	bk, err := h.store.Get(r.Context(), &objects.GetRequest{ID: id})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	version, err := h.checkIfMatch(r, bk)
+	if err != nil {
 		WriteError(w, err)
 		return
 	}
 
-	if err := h.store.Delete(r.Context(), &objects.DeleteRequest{ID: id}); err != nil {
+	if err := h.store.Delete(r.Context(), &objects.DeleteRequest{ID: id, Version: version}); err != nil {
 		WriteError(w, err)
 		return
 	}
 	WriteResponse(w, &objects.BookResponseWrapper{})
 }
+
+func (h *handler) Trash(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	limit, err := IntFromString(w, values.Get("limit"))
+	if err != nil {
+		return
+	}
+	list, err := h.store.ListTrash(r.Context(), &objects.ListRequest{
+		Limit: limit,
+		Title: values.Get("title"),
+	})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.BookResponseWrapper{Books: list})
+}
+
+func (h *handler) Restore(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := h.store.Restore(r.Context(), &objects.RestoreRequest{ID: id}); err != nil {
+		WriteError(w, err)
+		return
+	}
+	bk, err := h.store.Get(r.Context(), &objects.GetRequest{ID: id})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.Header().Set("ETag", ETag(bk.Version))
+	WriteResponse(w, &objects.BookResponseWrapper{Book: bk})
+}
+
+func (h *handler) Purge(w http.ResponseWriter, r *http.Request) {
+	retention := objects.DefaultTrashRetention
+	if v := r.URL.Query().Get("retention"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			WriteError(w, errors.ErrInvalidRetention)
+			return
+		}
+		retention = d
+	}
+	n, err := h.store.Purge(r.Context(), &objects.PurgeRequest{Before: time.Now().Add(-retention)})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.BookResponseWrapper{Purged: n})
+}
+
+func (h *handler) History(w http.ResponseWriter, r *http.Request) {
+	req := &objects.HistoryRequest{ID: mux.Vars(r)["id"]}
+	if at := r.URL.Query().Get("at"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			WriteError(w, errors.ErrInvalidTimestamp)
+			return
+		}
+		req.At = t
+		// view the book as it was at that time
+		bk, err := h.store.GetAsOf(r.Context(), req)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		WriteResponse(w, &objects.BookResponseWrapper{Book: bk})
+		return
+	}
+	list, err := h.store.History(r.Context(), req)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.BookResponseWrapper{History: list})
+}
+
+func (h *handler) Revert(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	revision, err := strconv.ParseInt(r.URL.Query().Get("revision"), 10, 64)
+	if err != nil || revision < 1 {
+		WriteError(w, errors.ErrInvalidRevision)
+		return
+	}
+	// check if book exists.
+	cur, err := h.store.Get(r.Context(), &objects.GetRequest{ID: id})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	version, err := h.checkIfMatch(r, cur)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	req := &objects.RevertRequest{ID: id, Revision: revision, Version: version}
+	// the revision is checked like an update, the library may have changed its ratings since
+	old, err := h.store.GetRevision(r.Context(), req)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if err = objects.Validate(old, objects.TenantFromContext(r.Context()).BookRules()...); err != nil {
+		WriteError(w, err)
+		return
+	}
+	bk, err := h.store.Revert(r.Context(), req)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.Header().Set("ETag", ETag(bk.Version))
+	WriteResponse(w, &objects.BookResponseWrapper{Book: bk})
+}
+
+// checkIfMatch verifies the If-Match precondition against the current book, returning
+// the version the change must be conditional on, or zero when no precondition was given
+// and none is required
+func (h *handler) checkIfMatch(r *http.Request, bk *objects.Book) (int64, error) {
+	im := r.Header.Get("If-Match")
+	if im == "" {
+		if h.cfg.RequireIfMatch {
+			return 0, errors.ErrPreconditionRequired
+		}
+		return 0, nil
+	}
+	if !MatchETag(im, bk.Version, false) {
+		return 0, errors.ErrPreconditionFailed
+	}
+	return bk.Version, nil
+}
+
+// bookDocument patchable JSON document of a book, every general detail is present even when empty
+func bookDocument(bk *objects.Book) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          bk.ID,
+		"isbn":        bk.ISBN,
+		"title":       bk.Title,
+		"author":      bk.Author,
+		"publisher":   bk.Publisher,
+		"publishdate": bk.PublishDate,
+		"status":      bk.Status,
+		"rating":      bk.Rating,
+		"call_number": bk.CallNumber,
+	}
+}
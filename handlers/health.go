@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/redeam/gobooks/objects"
+)
+
+// healthCheckTimeout time each readiness check has to pass
+const healthCheckTimeout = 2 * time.Second
+
+// HealthCheck named check of something the api needs to serve requests, e.g the database
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// IHealthHandler is the handler interface of the liveness and readiness endpoints
+type IHealthHandler interface {
+	// Live reports the process is alive
+	Live(w http.ResponseWriter, r *http.Request)
+	// Ready reports whether every check passes, with the outcome of each
+	Ready(w http.ResponseWriter, r *http.Request)
+	// Drain fails the readiness from now on, once the server is stopping
+	Drain()
+}
+
+type healthHandler struct {
+	checks   []HealthCheck
+	draining int32
+}
+
+// NewHealthHandler return current IHealthHandler implementation, ready when every check passes
+func NewHealthHandler(checks ...HealthCheck) IHealthHandler {
+	return &healthHandler{checks: checks}
+}
+
+func (h *healthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	WriteResponse(w, &objects.HealthResponseWrapper{Status: objects.HealthOK})
+}
+
+func (h *healthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	res := &objects.HealthResponseWrapper{Status: objects.HealthOK, Checks: map[string]*objects.HealthCheckResult{}}
+	shutdown := &objects.HealthCheckResult{Status: objects.HealthOK}
+	if atomic.LoadInt32(&h.draining) == 1 {
+		shutdown.Status, shutdown.Error = objects.HealthFailing, "the server is stopping"
+	}
+	res.Checks["shutdown"] = shutdown
+	for _, c := range h.checks {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		start := time.Now()
+		err := c.Check(ctx)
+		cancel()
+		result := &objects.HealthCheckResult{Status: objects.HealthOK, DurationMS: time.Since(start).Milliseconds()}
+		if err != nil {
+			result.Status, result.Error = objects.HealthFailing, err.Error()
+		}
+		res.Checks[c.Name] = result
+	}
+	for _, result := range res.Checks {
+		if result.Status == objects.HealthFailing {
+			res.Status = objects.HealthFailing
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	WriteResponse(w, res)
+}
+
+func (h *healthHandler) Drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
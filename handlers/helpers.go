@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
 )
 
 // Response helper used to write reponse
@@ -15,10 +18,31 @@ type Response interface {
 	StatusCode() int
 }
 
-// WriteResponse write the response to http response stream
+// WriteResponse write the response to http response stream, with the negotiated encoder
 func WriteResponse(w http.ResponseWriter, res Response) {
+	enc := encoderOf(w)
+	buf := &bytes.Buffer{}
+	if err := enc.Encode(buf, res); err != nil {
+		if err != errNotEncodable {
+			log.Println(err)
+		}
+		enc = jsonEncoder{}
+		buf.Reset()
+		_ = enc.Encode(buf, res)
+	}
+	contentType := enc.ContentType()
+	if _, ok := res.(*errors.Error); ok && problemTypes[contentType] != "" {
+		contentType = problemTypes[contentType]
+	}
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(res.StatusCode())
-	_, _ = w.Write(res.JSON())
+	_, _ = w.Write(buf.Bytes())
+}
+
+// problem details media types of the encoders' media types
+var problemTypes = map[string]string{
+	"application/json": errors.ProblemContentType,
+	"application/xml":  "application/problem+xml",
 }
 
 // WriteResponse write the response to http response stream
@@ -28,6 +52,10 @@ func WriteError(w http.ResponseWriter, err error) {
 		log.Println(err)
 		res = errors.ErrInternal
 	}
+	if ew, ok := w.(*encodingWriter); ok {
+		res = res.WithInstance(ew.instance).Localize(ew.lang)
+		w.Header().Set("Content-Language", ew.lang)
+	}
 	WriteResponse(w, res)
 }
 
@@ -44,6 +72,16 @@ func IntFromString(w http.ResponseWriter, v string) (int, error) {
 	return res, err
 }
 
+// SortFromString order of listed books, SortID or SortShelf
+func SortFromString(w http.ResponseWriter, v string) (string, error) {
+	switch v {
+	case "", objects.SortID, objects.SortShelf:
+		return v, nil
+	}
+	WriteError(w, errors.ErrInvalidSort)
+	return "", errors.ErrInvalidSort
+}
+
 func Unmarshal(w http.ResponseWriter, data []byte, v interface{}) error {
 	if d := string(data); d == "null" || d == "" {
 		WriteError(w, errors.ErrObjectIsRequired)
@@ -56,3 +94,23 @@ func Unmarshal(w http.ResponseWriter, data []byte, v interface{}) error {
 	}
 	return err
 }
+
+// ETag strong entity tag of the given book version
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// MatchETag reports whether an If-Match or If-None-Match header value matches the version,
+// weak comparison also accepts weak entity tags as used by If-None-Match
+func MatchETag(header string, version int64, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == ETag(version) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+)
+
+// IdempotencyKeyHeader header naming a request, so its retries aren't made again
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotent makes a request with an Idempotency-Key once: retries with the same key and body get
+// the response of the first request, until the key expires, and requests with the same key and
+// another body a 422. Requests that fail on the server side can be retried, and so can those
+// whose server went away before they completed, once their lease is over.
+func (h *handler) idempotent(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		next(w, r)
+		return
+	}
+	if len(key) > objects.MaxIdempotencyKeyLength {
+		WriteError(w, errors.ErrInvalidIdempotencyKey)
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, errors.ErrUnprocessableEntity)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	sum := sha256.Sum256([]byte(r.Method + " " + r.URL.Path + "\n" + string(data)))
+	// anonymous clients are told apart by their address
+	k := &objects.IdempotencyKey{
+		Client:      "ip:" + clientIP(r, h.cfg.TrustProxy),
+		Key:         key,
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+	if p := objects.PrincipalFromContext(r.Context()); p != nil {
+		k.Client = p.Subject
+	}
+	existing, err := h.store.ReserveIdempotencyKey(r.Context(), k)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if existing != nil {
+		switch {
+		case existing.Fingerprint != k.Fingerprint:
+			WriteError(w, errors.ErrIdempotencyKeyReused)
+		case existing.Status == 0:
+			WriteError(w, errors.ErrIdempotencyKeyInUse)
+		default:
+			replay(w, existing)
+		}
+		return
+	}
+
+	// the key is released unless the response is recorded, so requests that fail on the server
+	// side or panic can be retried. The client may be gone by then, the key is kept all the same
+	recorded := false
+	defer func() {
+		if recorded {
+			return
+		}
+		if err := h.store.ReleaseIdempotencyKey(context.Background(), k); err != nil {
+			log.Println(err)
+		}
+	}()
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	if ew, ok := w.(*encodingWriter); ok {
+		// keep the negotiated encoder and language
+		cw := *ew
+		cw.ResponseWriter = rec
+		next(&cw, r)
+	} else {
+		next(rec, r)
+	}
+	if rec.status >= http.StatusInternalServerError {
+		return
+	}
+	k.Status, k.Body = rec.status, rec.body.Bytes()
+	k.ContentType, k.ETag = rec.Header().Get("Content-Type"), rec.Header().Get("ETag")
+	if err = h.store.CompleteIdempotencyKey(context.Background(), k); err != nil {
+		log.Println(err)
+		return
+	}
+	recorded = true
+}
+
+// replay writes the response recorded for a request made with an Idempotency-Key
+func replay(w http.ResponseWriter, k *objects.IdempotencyKey) {
+	w.Header().Set("Content-Type", k.ContentType)
+	if k.ETag != "" {
+		w.Header().Set("ETag", k.ETag)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(k.Status)
+	_, _ = w.Write(k.Body)
+}
+
+// responseRecorder response writer keeping a copy of the status and body it writes
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+	"github.com/redeam/gobooks/store"
+)
+
+// importOptions query options shared by the import endpoints
+type importOptions struct {
+	// only report the outcome of each book
+	dryRun bool
+	// update the book with the same ISBN instead of creating a new one
+	upsertISBN bool
+	// details of the books imported without them, formats such as MARC have no rating
+	defaults objects.Book
+}
+
+func parseImportOptions(r *http.Request) (*importOptions, error) {
+	values := r.URL.Query()
+	opts := &importOptions{}
+	if v := values.Get("dry_run"); v != "" {
+		var err error
+		if opts.dryRun, err = strconv.ParseBool(v); err != nil {
+			return nil, errors.ErrBadRequest
+		}
+	}
+	if v := values.Get("rating"); v != "" {
+		if opts.defaults.SetCSVField("rating", v); opts.defaults.Rating == 0 {
+			return nil, errors.ErrRatingIsRequired
+		}
+	}
+	switch values.Get("upsert") {
+	case "":
+	case "isbn":
+		opts.upsertISBN = true
+	default:
+		return nil, errors.ErrInvalidUpsert
+	}
+	return opts, nil
+}
+
+// importedBook book read by an import, with the values of the general details its format
+// supplied by CSV column. Upserts only change those, so the details an import has no place for
+// are kept
+type importedBook struct {
+	*objects.Book
+	cells map[string]string
+}
+
+// upserted copy of the existing book with the details the import supplied replaced. Books
+// always have a status and a rating, so blank ones are left as they are; any other value is
+// set as read, for the checks to report it
+func (bk *importedBook) upserted(old *objects.Book) *objects.Book {
+	upd := *old
+	for column, value := range bk.cells {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if strings.TrimSpace(value) == "" && (column == "status" || column == "rating") {
+			continue
+		}
+		if column == "rating" {
+			// anything but a number is left unset by SetCSVField, not kept
+			upd.Rating = 0
+		}
+		upd.SetCSVField(column, value)
+	}
+	return &upd
+}
+
+// importBooks validates the books read by an import with the same rules as the batch endpoint,
+// and imports them all together or, if any is invalid, none of them. results[i] holds the row
+// and any warnings of books[i] and is completed with its outcome.
+func (h *handler) importBooks(w http.ResponseWriter, r *http.Request, opts *importOptions,
+	books []*importedBook, results []*objects.BatchResult) {
+	if len(books) == 0 {
+		WriteError(w, errors.ErrObjectIsRequired)
+		return
+	}
+	ops := make([]*objects.BatchOperation, len(books))
+	valid := true
+	isbns := map[string]bool{}
+	for i, bk := range books {
+		result := results[i]
+		ops[i] = &objects.BatchOperation{Op: objects.OpCreate, Book: bk.Book}
+		result.Op, result.Status = objects.OpCreate, http.StatusOK
+		// the ISBN is matched without separators
+		key := *bk.Book
+		key.Normalize()
+		if opts.upsertISBN && key.ISBN != "" {
+			if isbns[key.ISBN] {
+				setResultError(result, errors.ErrDuplicateISBN)
+				valid = false
+				continue
+			}
+			isbns[key.ISBN] = true
+			existing, err := h.store.List(r.Context(), &objects.ListRequest{Limit: 1, ISBN: key.ISBN})
+			if err != nil {
+				WriteError(w, err)
+				return
+			}
+			if len(existing) > 0 {
+				// imports are exempt from RequireIfMatch: the update is conditional on the
+				// version read here, the client never saw the book it overwrites
+				old := existing[0]
+				ops[i] = &objects.BatchOperation{Op: objects.OpUpdate, ID: old.ID, Version: old.Version, Book: bk.upserted(old)}
+				result.Op, result.ID = objects.OpUpdate, old.ID
+			}
+		}
+		if ops[i].Op == objects.OpCreate && bk.Rating == 0 {
+			bk.Rating = opts.defaults.Rating
+		}
+		if err := validateBatchOperation(r.Context(), ops[i]); err != nil {
+			setResultError(result, err)
+			valid = false
+		}
+	}
+
+	res := &objects.BookResponseWrapper{Results: results}
+	if !valid {
+		res.Code = errors.ErrInvalidImport.Code
+	}
+	if !valid || opts.dryRun {
+		writeResults(w, res)
+		return
+	}
+	err := h.store.Transaction(r.Context(), func(st store.IBookStore) error {
+		for i, op := range ops {
+			if err := applyBatchOperation(r.Context(), st, op, results[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeResults(w, res)
+}
+
+// exportPages pages through the books matching the list filters of the request by id, so only a
+// page is held in memory. start is called once the first page is read, before anything is written,
+// then write with every page. It reports whether the export was started.
+func (h *handler) exportPages(w http.ResponseWriter, r *http.Request, start func(), write func(list []*objects.Book) error) bool {
+	values := r.URL.Query()
+	req := &objects.ListRequest{
+		Title:           values.Get("title"),
+		ISBN:            values.Get("isbn"),
+		RecordReference: values.Get("record_reference"),
+		Branch:          values.Get("branch"),
+	}
+	list, err := h.store.List(r.Context(), req)
+	if err != nil {
+		WriteError(w, err)
+		return false
+	}
+	start()
+	for {
+		if err = write(list); err != nil {
+			break
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if len(list) < objects.MaxListLimit {
+			break
+		}
+		req.After, req.Limit = list[len(list)-1].ID, 0
+		if list, err = h.store.List(r.Context(), req); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		// the status has already been written, the export is cut short
+		log.Println(err)
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+	"github.com/redeam/gobooks/store"
+)
+
+// IKeyHandler is the handler interface of the API key endpoints
+type IKeyHandler interface {
+	Issue(w http.ResponseWriter, r *http.Request)
+	List(w http.ResponseWriter, r *http.Request)
+	Revoke(w http.ResponseWriter, r *http.Request)
+}
+
+type keyHandler struct {
+	keys store.IKeyStore
+}
+
+// NewKeyHandler return current IKeyHandler implementation
+func NewKeyHandler(keys store.IKeyStore) IKeyHandler {
+	return &keyHandler{keys: keys}
+}
+
+func (h *keyHandler) Issue(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, errors.ErrUnprocessableEntity)
+		return
+	}
+	req := &objects.IssueKeyRequest{}
+	if Unmarshal(w, data, req) != nil {
+		return
+	}
+	if err = objects.Validate(req); err != nil {
+		WriteError(w, err)
+		return
+	}
+	key, err := h.keys.IssueKey(r.Context(), req)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.KeyResponseWrapper{Key: key})
+}
+
+func (h *keyHandler) List(w http.ResponseWriter, r *http.Request) {
+	list, err := h.keys.ListKeys(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.KeyResponseWrapper{Keys: list})
+}
+
+func (h *keyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	err := h.keys.RevokeKey(r.Context(), &objects.RevokeKeyRequest{ID: mux.Vars(r)["id"]})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.KeyResponseWrapper{})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/marc"
+	"github.com/redeam/gobooks/objects"
+)
+
+func (h *handler) ExportMARC(w http.ResponseWriter, r *http.Request) {
+	xml := false
+	contentType, filename := marc.BinaryContentType, "books.mrc"
+	switch r.URL.Query().Get("format") {
+	case "", "marc":
+	case "marcxml":
+		xml, contentType, filename = true, marc.XMLContentType, "books.xml"
+	default:
+		WriteError(w, errors.ErrInvalidMARCFormat)
+		return
+	}
+	xw := marc.NewXMLWriter(w)
+	started := h.exportPages(w, r, func() {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.Header().Set("X-Unmapped-Fields", strings.Join(marc.UnmappedBookFields, ", "))
+	}, func(list []*objects.Book) error {
+		records := make([]*marc.Record, len(list))
+		for i, bk := range list {
+			records[i] = marc.FromBook(bk)
+		}
+		if xml {
+			return xw.Write(records...)
+		}
+		return marc.WriteBinary(w, records...)
+	})
+	if started && xml {
+		if err := xw.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (h *handler) ImportMARC(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseImportOptions(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	var records []*marc.Record
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case marc.BinaryContentType:
+		records, err = marc.ReadBinary(r.Body)
+	case marc.XMLContentType, "application/xml", "text/xml":
+		records, err = marc.ReadXML(r.Body)
+	default:
+		WriteError(w, errors.ErrUnsupportedMARCMediaType)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		WriteError(w, errors.ErrInvalidMARC)
+		return
+	}
+	books := make([]*importedBook, len(records))
+	results := make([]*objects.BatchResult, len(records))
+	for i, rec := range records {
+		bk, report := marc.ToBook(rec)
+		books[i] = &importedBook{Book: bk, cells: marcCells(bk)}
+		results[i] = &objects.BatchResult{Row: i + 1, Warnings: report}
+	}
+	h.importBooks(w, r, opts, books, results)
+}
+
+// marcCells details a record supplied by CSV column, those ToBook set
+func marcCells(bk *objects.Book) map[string]string {
+	cells := map[string]string{}
+	for _, column := range objects.CSVColumns {
+		if value := bk.CSVField(column); value != "" {
+			cells[column] = value
+		}
+	}
+	return cells
+}
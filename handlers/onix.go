@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+	"github.com/redeam/gobooks/onix"
+)
+
+func (h *handler) ImportONIX(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseImportOptions(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	var feed io.Reader
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/xml", "text/xml":
+		feed = r.Body
+	case "multipart/form-data":
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			log.Println(err)
+			WriteError(w, errors.ErrObjectIsRequired)
+			return
+		}
+		defer f.Close()
+		feed = f
+	default:
+		WriteError(w, errors.ErrUnsupportedONIXMediaType)
+		return
+	}
+
+	// products are ingested one by one, ingesting a feed again leaves its books as they are
+	res := &objects.BookResponseWrapper{Summary: &objects.ImportSummary{}}
+	err = onix.ReadProducts(feed, func(p *onix.Product) error {
+		result := &objects.BatchResult{Row: len(res.Results) + 1}
+		res.Results = append(res.Results, result)
+		h.ingestProduct(r.Context(), opts, p, result, false)
+		switch result.Op {
+		case objects.OpCreate:
+			res.Summary.Created++
+		case objects.OpUpdate:
+			res.Summary.Updated++
+		default:
+			res.Summary.Skipped++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println(err)
+		if len(res.Results) == 0 {
+			WriteError(w, errors.ErrInvalidONIX)
+			return
+		}
+		// the products before the error were ingested, report them with the error
+		res.Code = errors.ErrInvalidONIX.Code
+		res.Results = append(res.Results, &objects.BatchResult{
+			Row:    len(res.Results) + 1,
+			Op:     objects.OpSkip,
+			Status: errors.ErrInvalidONIX.Code,
+			Error:  errors.ErrInvalidONIX.Message,
+		})
+	}
+	writeResults(w, res)
+}
+
+// ingestProduct creates or updates the book of a product, matched on its record reference. A
+// create losing the race to another one is retried once as an update.
+func (h *handler) ingestProduct(ctx context.Context, opts *importOptions, p *onix.Product, result *objects.BatchResult, retried bool) {
+	bk, report := p.ToBook()
+	result.Warnings = report
+	skip := func(err error) {
+		result.Op, result.Status = objects.OpSkip, http.StatusOK
+		if err != nil {
+			setResultError(result, err)
+		}
+	}
+	if bk.RecordReference == "" {
+		skip(errors.ErrRecordReferenceIsRequired)
+		return
+	}
+	if p.NotificationType == onix.NotificationDelete {
+		// deletion notices are left for librarians to act on
+		result.Warnings = append(result.Warnings, "NotificationType: deletion notice not applied")
+		skip(nil)
+		return
+	}
+
+	// the book of a record reference may be in the trash, it is left there for librarians
+	existing, err := h.store.List(ctx, &objects.ListRequest{Limit: 1, RecordReference: bk.RecordReference, WithTrash: true})
+	if err != nil {
+		skip(err)
+		return
+	}
+	if len(existing) > 0 && existing[0].DeletedOn != nil {
+		result.ID = existing[0].ID
+		skip(errors.ErrRecordReferenceInTrash)
+		return
+	}
+	op := &objects.BatchOperation{Op: objects.OpCreate, Book: bk}
+	if len(existing) > 0 {
+		// ONIX has no status, rating nor call number, the book keeps its own
+		old := existing[0]
+		bk.Status, bk.Rating, bk.CallNumber = old.Status, old.Rating, old.CallNumber
+		// as in the other imports, the update is conditional on the version read here
+		op.Op, op.ID, op.Version = objects.OpUpdate, old.ID, old.Version
+		result.ID = old.ID
+	} else if bk.Rating == 0 {
+		bk.Rating = opts.defaults.Rating
+	}
+	if err := validateBatchOperation(ctx, op); err != nil {
+		skip(err)
+		return
+	}
+	// stored ISBNs are normalized, so are the compared ones
+	if isbn, ok := objects.NormalizeISBN(bk.ISBN); ok {
+		bk.ISBN = isbn
+	}
+	if len(existing) > 0 && sameDetails(bk, existing[0]) {
+		skip(nil)
+		return
+	}
+	result.Op, result.Status = op.Op, http.StatusOK
+	if opts.dryRun {
+		return
+	}
+	err = applyBatchOperation(ctx, h.store, op, result)
+	if err == errors.ErrRecordReferenceExists && !retried {
+		// the book was created by another ingestion since it was looked up, it is updated instead
+		*result = objects.BatchResult{Row: result.Row}
+		h.ingestProduct(ctx, opts, p, result, true)
+		return
+	}
+	if err != nil {
+		result.Op = objects.OpSkip
+	}
+}
+
+// sameDetails reports whether two books have the same general details
+func sameDetails(a, b *objects.Book) bool {
+	return a.ISBN == b.ISBN && a.Title == b.Title && a.Author == b.Author && a.Publisher == b.Publisher &&
+		a.PublishDate == b.PublishDate && a.Status == b.Status && a.Rating == b.Rating && a.CallNumber == b.CallNumber
+}
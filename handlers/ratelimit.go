@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+	"github.com/redeam/gobooks/store"
+)
+
+// RateLimitConfig limits of the requests of each client
+type RateLimitConfig struct {
+	// Read limit of the GET and HEAD requests, Write of the others
+	Read, Write objects.RateLimit
+	// Address limit of all the requests from an address, whatever their credentials
+	Address objects.RateLimit
+	// TrustProxy takes the address of anonymous clients from the X-Forwarded-For header set by
+	// the proxy in front of the server, instead of the address of the connection
+	TrustProxy bool
+}
+
+// RateLimit limits the rate of requests of each client, known by the id of its credentials or
+// else by its address, in the library the request is made to. Requests over the limit get a 429 with a
+// Retry-After; the others are made with RateLimit headers telling how many are left. Requests
+// are let through when the rate store fails, the api staying up without it.
+func RateLimit(rates store.IRateStore, cfg RateLimitConfig) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit, kind := cfg.Write, "write"
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				limit, kind = cfg.Read, "read"
+			}
+			client := "ip:" + clientIP(r, cfg.TrustProxy)
+			if p := objects.PrincipalFromContext(r.Context()); p != nil {
+				client = p.ID
+			}
+			key := objects.TenantFromContext(r.Context()).ID + "/" + client + "/" + kind
+			if takeRate(w, r, rates, key, limit) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// AddressRateLimit limits the rate of all the requests from each address, as RateLimit does but
+// in all the libraries at once. It goes before the library is resolved and the credentials are
+// checked, so clients flooding the api with unknown hosts or bad credentials are limited too.
+func AddressRateLimit(rates store.IRateStore, cfg RateLimitConfig) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if takeRate(w, r, rates, "ip:"+clientIP(r, cfg.TrustProxy)+"/all", cfg.Address) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// takeRate takes a token from the bucket with the key, writing the RateLimit headers. It reports
+// whether the request can be made, having written the 429 when it can't.
+func takeRate(w http.ResponseWriter, r *http.Request, rates store.IRateStore, key string, limit objects.RateLimit) bool {
+	if limit.Requests == 0 {
+		return true
+	}
+	res, err := rates.Take(r.Context(), key, limit)
+	if err != nil {
+		log.Println(err)
+		return true
+	}
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", seconds(res.Reset))
+	h.Set("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+seconds(limit.Period))
+	if !res.Allowed {
+		h.Set("Retry-After", seconds(res.RetryAfter))
+		WriteError(w, errors.ErrTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// clientIP address of the client making the request, the last one appended to X-Forwarded-For
+// when the proxy setting it is trusted
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			hops := strings.Split(fwd[len(fwd)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// seconds whole number of seconds of d, rounded up so clients don't retry too early
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/redeam/gobooks/objects"
+)
+
+// streamFlushRows number of books written between flushes of a stream
+const streamFlushRows = 100
+
+func (h *handler) Stream(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	limit, err := IntFromString(w, values.Get("limit"))
+	if err != nil {
+		return
+	}
+	sort, err := SortFromString(w, values.Get("sort"))
+	if err != nil {
+		return
+	}
+	req := &objects.ListRequest{
+		Limit:           limit,
+		Title:           values.Get("title"),
+		ISBN:            values.Get("isbn"),
+		RecordReference: values.Get("record_reference"),
+		Branch:          values.Get("branch"),
+		Sort:            sort,
+	}
+	flush := func() {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	start := func() {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+	enc := json.NewEncoder(w)
+	rows := 0
+	err = h.store.Stream(r.Context(), req, func(bk *objects.Book) error {
+		if rows == 0 {
+			start()
+		}
+		rows++
+		if err := enc.Encode(bk); err != nil {
+			return err
+		}
+		if rows%streamFlushRows == 0 {
+			flush()
+		}
+		return nil
+	})
+	if rows == 0 {
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		start()
+	}
+	if err != nil {
+		// the status has already been written, the stream is cut short, e.g when the client went away
+		log.Println(err)
+		return
+	}
+	flush()
+}
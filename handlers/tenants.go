@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+	"github.com/redeam/gobooks/store"
+)
+
+// TenantHeader header naming the library a request is made to
+const TenantHeader = "X-Tenant"
+
+// ResolveTenant sets the library of requests, named by the X-Tenant header or else by the
+// subdomain of domain the request is made to, e.g springfield.books.example.com. Requests
+// naming neither are made to the default library, and those naming an unknown one get a 404.
+func ResolveTenant(tenants store.ITenantStore, domain string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(TenantHeader)
+			if id == "" {
+				id = subdomain(r.Host, domain)
+			}
+			if id == "" {
+				id = objects.DefaultTenantID
+			}
+			t, err := tenants.GetTenant(r.Context(), id)
+			if err != nil {
+				WriteError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(objects.WithTenant(r.Context(), t)))
+		})
+	}
+}
+
+// subdomain label of host right before domain, empty when host isn't a subdomain of it
+func subdomain(host, domain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host, domain = strings.ToLower(host), strings.ToLower(domain)
+	if domain == "" || !strings.HasSuffix(host, "."+domain) {
+		return ""
+	}
+	labels := strings.Split(strings.TrimSuffix(host, "."+domain), ".")
+	return labels[len(labels)-1]
+}
+
+// ITenantHandler is the handler interface of the library endpoints
+type ITenantHandler interface {
+	Current(w http.ResponseWriter, r *http.Request)
+	Create(w http.ResponseWriter, r *http.Request)
+	List(w http.ResponseWriter, r *http.Request)
+	Get(w http.ResponseWriter, r *http.Request)
+	Update(w http.ResponseWriter, r *http.Request)
+}
+
+type tenantHandler struct {
+	tenants store.ITenantStore
+}
+
+// NewTenantHandler return current ITenantHandler implementation
+func NewTenantHandler(tenants store.ITenantStore) ITenantHandler {
+	return &tenantHandler{tenants: tenants}
+}
+
+// Current settings of the library the request is made to
+func (h *tenantHandler) Current(w http.ResponseWriter, r *http.Request) {
+	WriteResponse(w, &objects.TenantResponseWrapper{Tenant: objects.TenantFromContext(r.Context())})
+}
+
+func (h *tenantHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if !provisioning(w, r) {
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, errors.ErrUnprocessableEntity)
+		return
+	}
+	t := &objects.Tenant{}
+	if Unmarshal(w, data, t) != nil {
+		return
+	}
+	if err = objects.Validate(t); err != nil {
+		WriteError(w, err)
+		return
+	}
+	if err = h.tenants.CreateTenant(r.Context(), t); err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.TenantResponseWrapper{Tenant: t})
+}
+
+func (h *tenantHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !provisioning(w, r) {
+		return
+	}
+	list, err := h.tenants.ListTenants(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.TenantResponseWrapper{Tenants: list})
+}
+
+func (h *tenantHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if !provisioning(w, r) {
+		return
+	}
+	t, err := h.tenants.GetTenant(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.TenantResponseWrapper{Tenant: t})
+}
+
+func (h *tenantHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if !provisioning(w, r) {
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, errors.ErrUnprocessableEntity)
+		return
+	}
+	t := &objects.Tenant{}
+	if Unmarshal(w, data, t) != nil {
+		return
+	}
+	// the id can't be changed, the library's books and keys refer to it
+	t.ID = mux.Vars(r)["id"]
+	if err = objects.Validate(t); err != nil {
+		WriteError(w, err)
+		return
+	}
+	if err = h.tenants.UpdateTenant(r.Context(), t); err != nil {
+		WriteError(w, err)
+		return
+	}
+	WriteResponse(w, &objects.TenantResponseWrapper{Tenant: t})
+}
+
+// provisioning checks the request is made to the default library, whose admins operate the
+// deployment; admins of the other libraries can't provision them
+func provisioning(w http.ResponseWriter, r *http.Request) bool {
+	if objects.TenantFromContext(r.Context()).ID != objects.DefaultTenantID {
+		WriteError(w, errors.ErrForbidden)
+		return false
+	}
+	return true
+}
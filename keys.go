@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+
+	"github.com/redeam/gobooks/objects"
+	"github.com/redeam/gobooks/store"
+)
+
+const keysUsage = `usage:
+  gobooks keys issue -name <name> [-scope read|read_write|admin] [-tenant <id>]
+  gobooks keys list [-tenant <id>]
+  gobooks keys revoke -id <id> [-tenant <id>]`
+
+// RunKeys manages the API keys from the command line, e.g to issue the first admin key
+func RunKeys(args Args, argv []string, out io.Writer) error {
+	if len(argv) == 0 {
+		return errors.New(keysUsage)
+	}
+	fs := flag.NewFlagSet("keys "+argv[0], flag.ContinueOnError)
+	fs.SetOutput(out)
+	name := fs.String("name", "", "name of the client the key is issued to")
+	scope := fs.String("scope", "read_write", "scope of the key: read, read_write or admin")
+	id := fs.String("id", "", "id of the key to revoke")
+	tenant := fs.String("tenant", objects.DefaultTenantID, "id of the library of the keys")
+	if err := fs.Parse(argv[1:]); err != nil {
+		return err
+	}
+
+	t, err := store.NewPostgresTenantStore(args.conn).GetTenant(context.Background(), *tenant)
+	if err != nil {
+		return err
+	}
+	ctx := objects.WithTenant(context.Background(), t)
+	keys := store.NewPostgresKeyStore(args.conn)
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	switch argv[0] {
+	case "issue":
+		req := objects.NewIssueKeyRequest(*name, *scope)
+		if err := objects.Validate(req); err != nil {
+			return err
+		}
+		key, err := keys.IssueKey(ctx, req)
+		if err != nil {
+			return err
+		}
+		// the secret can't be shown again
+		return enc.Encode(key)
+	case "list":
+		list, err := keys.ListKeys(ctx)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(list)
+	case "revoke":
+		return keys.RevokeKey(ctx, &objects.RevokeKeyRequest{ID: *id})
+	}
+	return errors.New(keysUsage)
+}
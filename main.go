@@ -3,6 +3,10 @@ package main
 import (
 	"log"
 	"os"
+	"time"
+
+	"github.com/redeam/gobooks/auth"
+	"github.com/redeam/gobooks/objects"
 )
 
 func main() {
@@ -16,6 +20,78 @@ func main() {
 	if port := os.Getenv("PORT"); port != "" {
 		args.port = ":" + port
 	}
+	args.policy = os.Getenv("POLICY_FILE")
+	// changes of books need an If-Match unless turned off
+	args.books.RequireIfMatch = os.Getenv("IF_MATCH_REQUIRED") != "false"
+	args.timeouts = Timeouts{
+		ReadHeader: 10 * time.Second,
+		Read:       time.Minute,
+		Write:      10 * time.Minute,
+		Idle:       2 * time.Minute,
+		Unready:    5 * time.Second,
+		Drain:      30 * time.Second,
+	}
+	for _, t := range []struct {
+		env     string
+		timeout *time.Duration
+	}{
+		{"HTTP_READ_HEADER_TIMEOUT", &args.timeouts.ReadHeader},
+		{"HTTP_READ_TIMEOUT", &args.timeouts.Read},
+		{"HTTP_WRITE_TIMEOUT", &args.timeouts.Write},
+		{"HTTP_IDLE_TIMEOUT", &args.timeouts.Idle},
+		{"SHUTDOWN_UNREADY_DELAY", &args.timeouts.Unready},
+		{"SHUTDOWN_DRAIN_TIMEOUT", &args.timeouts.Drain},
+	} {
+		if s := os.Getenv(t.env); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				log.Fatal(err)
+			}
+			*t.timeout = d
+		}
+	}
+	args.rateStore = os.Getenv("RATE_LIMIT_STORE")
+	args.rateLimit.TrustProxy = os.Getenv("RATE_LIMIT_TRUST_PROXY") == "true"
+	args.books.TrustProxy = args.rateLimit.TrustProxy
+	for _, l := range []struct {
+		env, def string
+		limit    *objects.RateLimit
+	}{
+		{"RATE_LIMIT_READ", "600/1m", &args.rateLimit.Read},
+		{"RATE_LIMIT_WRITE", "60/1m", &args.rateLimit.Write},
+		{"RATE_LIMIT_ADDRESS", "1200/1m", &args.rateLimit.Address},
+	} {
+		s, ok := os.LookupEnv(l.env)
+		if !ok {
+			s = l.def
+		}
+		limit, err := objects.ParseRateLimit(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*l.limit = limit
+	}
+	args.domain = os.Getenv("TENANT_DOMAIN")
+	args.oidc = auth.OIDCConfig{
+		JWKSURL:   os.Getenv("OIDC_JWKS_URL"),
+		Issuer:    os.Getenv("OIDC_ISSUER"),
+		Audience:  os.Getenv("OIDC_AUDIENCE"),
+		ClockSkew: time.Minute,
+	}
+	if skew := os.Getenv("OIDC_CLOCK_SKEW"); skew != "" {
+		d, err := time.ParseDuration(skew)
+		if err != nil {
+			log.Fatal(err)
+		}
+		args.oidc.ClockSkew = d
+	}
+	// manage API keys
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		if err := RunKeys(args, os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	// run server
 	if err := Run(args); err != nil {
 		log.Println(err)
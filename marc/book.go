@@ -0,0 +1,154 @@
+package marc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redeam/gobooks/objects"
+)
+
+// mapped subfields of the data fields read into a Book
+var mappedSubfields = map[string]string{
+	"020": "a",
+	"050": "ab",
+	"082": "ab",
+	"100": "a",
+	"245": "ab",
+	"260": "bc",
+	"264": "bc",
+}
+
+// control fields that only describe the record itself
+var recordControlFields = map[string]bool{
+	"001": true,
+	"003": true,
+	"005": true,
+}
+
+// ToBook maps a record to a Book, reporting the fields and subfields that were
+// left out or only partly kept
+func ToBook(rec *Record) (*objects.Book, []string) {
+	bk := &objects.Book{}
+	var report []string
+	if len(rec.Leader) == leaderLength && rec.Leader[9] != 'a' {
+		report = append(report, "leader/09: MARC-8 character coding, non-ASCII characters may be wrong")
+	}
+	for _, cf := range rec.ControlFields {
+		if !recordControlFields[cf.Tag] {
+			report = append(report, cf.Tag+": unmapped")
+		}
+	}
+	seen := map[string]bool{}
+	for _, df := range rec.DataFields {
+		codes, ok := mappedSubfields[df.Tag]
+		if !ok || (df.Tag == "264" && df.Ind2 != '1') {
+			report = append(report, df.Tag+": unmapped")
+			continue
+		}
+		// only the first 020, the first of 050 or 082, and the first of 260 or 264, are kept
+		key := df.Tag
+		switch key {
+		case "082":
+			key = "050"
+		case "264":
+			key = "260"
+		}
+		if seen[key] {
+			report = append(report, df.Tag+": repeated field dropped")
+			continue
+		}
+		seen[key] = true
+		for _, sf := range df.Subfields {
+			if !strings.ContainsRune(codes, rune(sf.Code)) {
+				report = append(report, fmt.Sprintf("%s$%c: unmapped", df.Tag, sf.Code))
+			}
+		}
+		switch df.Tag {
+		case "020":
+			// qualifiers such as "(pbk.)" follow the number
+			if fields := strings.Fields(df.Subfield('a')); len(fields) > 0 {
+				bk.ISBN = fields[0]
+			}
+		case "050", "082":
+			bk.CallNumber = callNumber(df)
+		case "100":
+			bk.Author = trimPunctuation(df.Subfield('a'))
+		case "245":
+			bk.Title = trimPunctuation(df.Subfield('a'))
+			if sub := trimPunctuation(df.Subfield('b')); sub != "" {
+				bk.Title += ": " + sub
+			}
+		case "260", "264":
+			bk.Publisher = trimPunctuation(df.Subfield('b'))
+			bk.PublishDate = trimPunctuation(df.Subfield('c'))
+		}
+	}
+	return bk, report
+}
+
+// UnmappedBookFields book fields MARC has no place for, left out of exported records
+var UnmappedBookFields = []string{"status", "rating"}
+
+// FromBook maps a Book to a record
+func FromBook(bk *objects.Book) *Record {
+	rec := &Record{Leader: DefaultLeader}
+	if bk.ID != "" {
+		rec.ControlFields = append(rec.ControlFields, &ControlField{Tag: "001", Value: bk.ID})
+	}
+	if bk.ISBN != "" {
+		rec.DataFields = append(rec.DataFields, dataField("020", ' ', ' ', &Subfield{'a', bk.ISBN}))
+	}
+	if cn, ok := objects.ParseCallNumber(bk.CallNumber); ok {
+		// assigned by the library, not by the Library of Congress
+		tag := "082"
+		if cn.Scheme == objects.LC {
+			tag = "050"
+		}
+		rec.DataFields = append(rec.DataFields, dataField(tag, ' ', '4',
+			&Subfield{'a', cn.Classification()}, &Subfield{'b', strings.Join(cn.Rest, " ")}))
+	}
+	if bk.Author != "" {
+		rec.DataFields = append(rec.DataFields, dataField("100", '1', ' ', &Subfield{'a', bk.Author}))
+	}
+	// ISBD punctuation separates the title from the statement that follows
+	title := dataField("245", '0', '0', &Subfield{'a', bk.Title})
+	if i := strings.Index(bk.Title, ": "); i > 0 {
+		title = dataField("245", '0', '0', &Subfield{'a', bk.Title[:i] + " :"}, &Subfield{'b', bk.Title[i+2:]})
+	}
+	rec.DataFields = append(rec.DataFields, title)
+	if bk.Publisher != "" || bk.PublishDate != "" {
+		rec.DataFields = append(rec.DataFields, dataField("264", ' ', '1', &Subfield{'b', bk.Publisher}, &Subfield{'c', bk.PublishDate}))
+	}
+	return rec
+}
+
+// callNumber call number of a 050 or 082 field, classification number then item number.
+// The slashes segmenting Dewey numbers are dropped, e.g 823/.914
+func callNumber(df *DataField) string {
+	cn := strings.ReplaceAll(strings.TrimSpace(df.Subfield('a')), "/", "")
+	if item := strings.TrimSpace(df.Subfield('b')); item != "" {
+		cn += " " + item
+	}
+	return cn
+}
+
+// dataField builds a data field, skipping empty subfields
+func dataField(tag string, ind1, ind2 byte, subfields ...*Subfield) *DataField {
+	df := &DataField{Tag: tag, Ind1: ind1, Ind2: ind2}
+	for _, sf := range subfields {
+		if sf.Value != "" {
+			df.Subfields = append(df.Subfields, sf)
+		}
+	}
+	return df
+}
+
+// trimPunctuation removes the trailing ISBD punctuation of a subfield, e.g "Penguin," or "Title /"
+func trimPunctuation(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimRight(s, " /:;,=")
+	if strings.HasSuffix(s, ".") && !strings.HasSuffix(s, "..") && strings.Count(s, ".") == 1 {
+		s = strings.TrimSuffix(s, ".")
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,216 @@
+// Package marc reads and writes MARC 21 bibliographic records, in the ISO 2709
+// binary transmission format and in MARCXML, and maps them to and from Books.
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Media types of the MARC formats
+const (
+	BinaryContentType = "application/marc"
+	XMLContentType    = "application/marcxml+xml"
+)
+
+// ISO 2709 delimiters
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+)
+
+const (
+	leaderLength         = 24
+	directoryEntryLength = 12
+)
+
+// Record MARC record with its leader and variable fields
+type Record struct {
+	Leader        string
+	ControlFields []*ControlField
+	DataFields    []*DataField
+}
+
+// ControlField variable control field, tags 001 to 009
+type ControlField struct {
+	Tag   string
+	Value string
+}
+
+// DataField variable data field with its indicators and subfields
+type DataField struct {
+	Tag       string
+	Ind1      byte
+	Ind2      byte
+	Subfields []*Subfield
+}
+
+// Subfield coded subfield of a data field
+type Subfield struct {
+	Code  byte
+	Value string
+}
+
+// Fields all data fields with the given tag
+func (r *Record) Fields(tag string) []*DataField {
+	var res []*DataField
+	for _, f := range r.DataFields {
+		if f.Tag == tag {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// Subfield first value of the given subfield code, empty if absent
+func (f *DataField) Subfield(code byte) string {
+	for _, s := range f.Subfields {
+		if s.Code == code {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// ReadBinary reads all the ISO 2709 records of r
+func ReadBinary(r io.Reader) ([]*Record, error) {
+	br := bufio.NewReader(r)
+	var records []*Record
+	for {
+		data, err := br.ReadBytes(recordTerminator)
+		// records are sometimes separated by line breaks
+		data = bytes.TrimLeft(data, "\r\n")
+		if err == io.EOF && len(bytes.TrimSpace(data)) == 0 {
+			return records, nil
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		rec, perr := parseBinary(data)
+		if perr != nil {
+			return nil, fmt.Errorf("record %d: %v", len(records)+1, perr)
+		}
+		records = append(records, rec)
+		if err == io.EOF {
+			return records, nil
+		}
+	}
+}
+
+func parseBinary(data []byte) (*Record, error) {
+	if len(data) < leaderLength+1 {
+		return nil, fmt.Errorf("record is too short")
+	}
+	rec := &Record{Leader: string(data[:leaderLength])}
+	base, ok := number(data[12:17])
+	if !ok || base <= leaderLength || base > len(data) {
+		return nil, fmt.Errorf("invalid base address of data")
+	}
+	directory := data[leaderLength : base-1]
+	if len(directory)%directoryEntryLength != 0 {
+		return nil, fmt.Errorf("invalid directory")
+	}
+	for i := 0; i < len(directory); i += directoryEntryLength {
+		entry := directory[i : i+directoryEntryLength]
+		tag := string(entry[:3])
+		length, ok1 := number(entry[3:7])
+		start, ok2 := number(entry[7:12])
+		if !ok1 || !ok2 || base+start+length > len(data) || length < 1 {
+			return nil, fmt.Errorf("invalid directory entry for tag %s", tag)
+		}
+		// drop the field terminator
+		field := data[base+start : base+start+length-1]
+		if isControlTag(tag) {
+			rec.ControlFields = append(rec.ControlFields, &ControlField{Tag: tag, Value: string(field)})
+			continue
+		}
+		if len(field) < 2 {
+			return nil, fmt.Errorf("missing indicators for tag %s", tag)
+		}
+		df := &DataField{Tag: tag, Ind1: field[0], Ind2: field[1]}
+		for _, sf := range bytes.Split(field[2:], []byte{subfieldDelimiter}) {
+			if len(sf) == 0 {
+				continue
+			}
+			df.Subfields = append(df.Subfields, &Subfield{Code: sf[0], Value: string(sf[1:])})
+		}
+		rec.DataFields = append(rec.DataFields, df)
+	}
+	return rec, nil
+}
+
+// number reads the unsigned decimal number of a leader or directory field, which must be
+// all digits
+func number(b []byte) (int, bool) {
+	n := 0
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, len(b) > 0
+}
+
+// WriteBinary writes the records in ISO 2709, computing their leader lengths and directories
+func WriteBinary(w io.Writer, records ...*Record) error {
+	for _, rec := range records {
+		if _, err := w.Write(rec.binary()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Record) binary() []byte {
+	var directory, fields bytes.Buffer
+	add := func(tag string, field []byte) {
+		field = append(field, fieldTerminator)
+		fmt.Fprintf(&directory, "%3s%04d%05d", tag, len(field), fields.Len())
+		fields.Write(field)
+	}
+	for _, cf := range r.ControlFields {
+		add(cf.Tag, []byte(cf.Value))
+	}
+	for _, df := range r.DataFields {
+		field := []byte{indicator(df.Ind1), indicator(df.Ind2)}
+		for _, sf := range df.Subfields {
+			field = append(field, subfieldDelimiter, sf.Code)
+			field = append(field, sf.Value...)
+		}
+		add(df.Tag, field)
+	}
+	directory.WriteByte(fieldTerminator)
+
+	leader := []byte(r.Leader)
+	if len(leader) != leaderLength {
+		leader = []byte(DefaultLeader)
+	}
+	base := leaderLength + directory.Len()
+	total := base + fields.Len() + 1
+	copy(leader[0:5], fmt.Sprintf("%05d", total))
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+
+	res := make([]byte, 0, total)
+	res = append(res, leader...)
+	res = append(res, directory.Bytes()...)
+	res = append(res, fields.Bytes()...)
+	return append(res, recordTerminator)
+}
+
+// DefaultLeader leader of new records: a new language material monograph in Unicode
+const DefaultLeader = "00000nam a2200000 i 4500"
+
+func isControlTag(tag string) bool {
+	return len(tag) == 3 && tag[0] == '0' && tag[1] == '0'
+}
+
+func indicator(b byte) byte {
+	if b == 0 {
+		return ' '
+	}
+	return b
+}
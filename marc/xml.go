@@ -0,0 +1,160 @@
+package marc
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// Namespace of MARCXML documents
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+type xmlRecord struct {
+	XMLName       xml.Name           `xml:"record"`
+	Leader        string             `xml:"leader"`
+	ControlFields []*xmlControlField `xml:"controlfield"`
+	DataFields    []*xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string         `xml:"tag,attr"`
+	Ind1      string         `xml:"ind1,attr"`
+	Ind2      string         `xml:"ind2,attr"`
+	Subfields []*xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ReadXML reads the records of a MARCXML collection, or of a single record document
+func ReadXML(r io.Reader) ([]*Record, error) {
+	dec := xml.NewDecoder(r)
+	var records []*Record
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+		xr := &xmlRecord{}
+		if err := dec.DecodeElement(xr, &start); err != nil {
+			return nil, err
+		}
+		records = append(records, xr.record())
+	}
+}
+
+// WriteXML writes the records as a MARCXML collection
+func WriteXML(w io.Writer, records ...*Record) error {
+	xw := NewXMLWriter(w)
+	if err := xw.Write(records...); err != nil {
+		return err
+	}
+	return xw.Close()
+}
+
+// XMLWriter writes a MARCXML collection record by record
+type XMLWriter struct {
+	w       io.Writer
+	enc     *xml.Encoder
+	started bool
+}
+
+// NewXMLWriter returns a writer of a MARCXML collection to w, Close ends the collection
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return &XMLWriter{w: w, enc: enc}
+}
+
+func (xw *XMLWriter) start() error {
+	if xw.started {
+		return nil
+	}
+	xw.started = true
+	if _, err := io.WriteString(xw.w, xml.Header); err != nil {
+		return err
+	}
+	return xw.enc.EncodeToken(collectionStart)
+}
+
+// Write writes the records to the collection
+func (xw *XMLWriter) Write(records ...*Record) error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if err := xw.enc.Encode(newXMLRecord(rec)); err != nil {
+			return err
+		}
+	}
+	return xw.enc.Flush()
+}
+
+// Close ends the collection
+func (xw *XMLWriter) Close() error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+	if err := xw.enc.EncodeToken(collectionStart.End()); err != nil {
+		return err
+	}
+	return xw.enc.Flush()
+}
+
+var collectionStart = xml.StartElement{
+	Name: xml.Name{Local: "collection"},
+	Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: Namespace}},
+}
+
+func (xr *xmlRecord) record() *Record {
+	rec := &Record{Leader: xr.Leader}
+	for _, cf := range xr.ControlFields {
+		rec.ControlFields = append(rec.ControlFields, &ControlField{Tag: cf.Tag, Value: cf.Value})
+	}
+	for _, df := range xr.DataFields {
+		f := &DataField{Tag: df.Tag, Ind1: firstByte(df.Ind1), Ind2: firstByte(df.Ind2)}
+		for _, sf := range df.Subfields {
+			f.Subfields = append(f.Subfields, &Subfield{Code: firstByte(sf.Code), Value: sf.Value})
+		}
+		rec.DataFields = append(rec.DataFields, f)
+	}
+	return rec
+}
+
+func newXMLRecord(rec *Record) *xmlRecord {
+	xr := &xmlRecord{Leader: rec.Leader}
+	if len(xr.Leader) != leaderLength {
+		xr.Leader = DefaultLeader
+	}
+	for _, cf := range rec.ControlFields {
+		xr.ControlFields = append(xr.ControlFields, &xmlControlField{Tag: cf.Tag, Value: cf.Value})
+	}
+	for _, df := range rec.DataFields {
+		f := &xmlDataField{Tag: df.Tag, Ind1: string(indicator(df.Ind1)), Ind2: string(indicator(df.Ind2))}
+		for _, sf := range df.Subfields {
+			f.Subfields = append(f.Subfields, &xmlSubfield{Code: string(sf.Code), Value: sf.Value})
+		}
+		xr.DataFields = append(xr.DataFields, f)
+	}
+	return xr
+}
+
+func firstByte(s string) byte {
+	if s == "" {
+		return ' '
+	}
+	return s[0]
+}
@@ -0,0 +1,117 @@
+package objects
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+)
+
+// Define enums for audit actions
+type action string
+
+const (
+	ActionCreate  action = "create"
+	ActionUpdate  action = "update"
+	ActionDelete  action = "delete"
+	ActionRestore action = "restore"
+	ActionPurge   action = "purge"
+	ActionRevert  action = "revert"
+	// ActionMove the book was shelved at another location
+	ActionMove action = "move"
+	// ActionTransfer the book was sent to another branch
+	ActionTransfer action = "transfer"
+	// ActionReceive the book was received by the branch it was sent to
+	ActionReceive action = "receive"
+)
+
+// AnonymousActor actor of changes made without a known user
+const AnonymousActor = "anonymous"
+
+// AuditEvent immutable record of a change made to a Book
+type AuditEvent struct {
+	ID       uint64 `gorm:"primary_key" json:"-"`
+	TenantID string `gorm:"index;not null;default:default" json:"-"`
+	BookID   string `gorm:"index" json:"book_id"`
+	// Revision version of the book after the change
+	Revision  int64     `json:"revision"`
+	Action    action    `json:"action"`
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `gorm:"index" json:"timestamp"`
+	// Snapshots of the book around the change, Before is empty on create and After on purge
+	Before JSONDocument `gorm:"type:jsonb" json:"before,omitempty"`
+	After  JSONDocument `gorm:"type:jsonb" json:"after,omitempty"`
+	// Changes field by field, of the form {"title": {"from": "a", "to": "b"}}
+	Changes JSONDocument `gorm:"type:jsonb" json:"changes,omitempty"`
+}
+
+// HistoryRequest for retrieving the change history of a Book
+type HistoryRequest struct {
+	ID string `json:"id"`
+	// optional point in time to view the Book as of
+	At time.Time `json:"at"`
+}
+
+// RevertRequest to restore all details of a Book from a previous revision
+type RevertRequest struct {
+	ID       string `json:"id"`
+	Revision int64  `json:"revision"`
+	// expected version of the book, zero skips the check
+	Version int64 `json:"-"`
+}
+
+// JSONDocument raw JSON value stored in a jsonb column
+type JSONDocument []byte
+
+// Value implements the driver Valuer interface
+func (d JSONDocument) Value() (driver.Value, error) {
+	if len(d) == 0 {
+		return nil, nil
+	}
+	return string(d), nil
+}
+
+// Scan implements the sql Scanner interface
+func (d *JSONDocument) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		*d = append(JSONDocument{}, v...)
+	case string:
+		*d = JSONDocument(v)
+	default:
+		*d = nil
+	}
+	return nil
+}
+
+// MarshalJSON embeds the document as is
+func (d JSONDocument) MarshalJSON() ([]byte, error) {
+	if len(d) == 0 {
+		return []byte("null"), nil
+	}
+	return d, nil
+}
+
+// UnmarshalJSON keeps a copy of the document
+func (d *JSONDocument) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*d = nil
+		return nil
+	}
+	*d = append(JSONDocument{}, b...)
+	return nil
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the actor responsible for changes
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext actor responsible for changes, AnonymousActor if unknown
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
@@ -0,0 +1,175 @@
+package objects
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/redeam/gobooks/errors"
+)
+
+// Define enums for API key scopes
+type scope string
+
+const (
+	// ScopeRead keys can only read books
+	ScopeRead scope = "read"
+	// ScopeReadWrite keys can also create, change and delete books
+	ScopeReadWrite scope = "read_write"
+	// ScopeAdmin keys can also manage keys and purge the trash
+	ScopeAdmin scope = "admin"
+)
+
+// scopeRanks scopes in the order they grant access, each grants the ones before
+var scopeRanks = map[scope]int{ScopeRead: 1, ScopeReadWrite: 2, ScopeAdmin: 3}
+
+// HighestScope highest of the scopes named in names, ScopeRead if none is, e.g to map the
+// scopes of a token
+func HighestScope(names []string) scope {
+	highest := ScopeRead
+	for _, name := range names {
+		if s := scope(name); scopeRanks[s] > scopeRanks[highest] {
+			highest = s
+		}
+	}
+	return highest
+}
+
+// Role role of the clients with the scope: read keys are patrons', read_write keys librarians'
+func (s scope) Role() role {
+	switch s {
+	case ScopeAdmin:
+		return RoleAdmin
+	case ScopeReadWrite:
+		return RoleLibrarian
+	}
+	return RolePatron
+}
+
+// Define enums for roles
+type role string
+
+const (
+	// RoleAnonymous role of the clients without credentials
+	RoleAnonymous role = "anonymous"
+	// RolePatron role of the library's patrons
+	RolePatron role = "patron"
+	// RoleLibrarian role of the staff maintaining the catalog
+	RoleLibrarian role = "librarian"
+	// RoleAdmin role of the staff administering the api
+	RoleAdmin role = "admin"
+)
+
+// roleRanks roles in the order of the staff hierarchy
+var roleRanks = map[role]int{RoleAnonymous: 0, RolePatron: 1, RoleLibrarian: 2, RoleAdmin: 3}
+
+// IsRole reports whether name is the name of a role
+func IsRole(name string) bool {
+	_, ok := roleRanks[role(name)]
+	return ok
+}
+
+// HighestRole highest of the roles named in names, e.g in the roles claim of a token, and
+// whether any was
+func HighestRole(names []string) (role, bool) {
+	highest, found := RoleAnonymous, false
+	for _, name := range names {
+		if r := role(name); IsRole(name) && (!found || roleRanks[r] > roleRanks[highest]) {
+			highest, found = r, true
+		}
+	}
+	return highest, found
+}
+
+// APIKey key of an API client, only the hash of its secret is stored
+type APIKey struct {
+	ID string `gorm:"primary_key" json:"id"`
+	// TenantID library the key was issued by, its client can't use any other
+	TenantID string `gorm:"index;not null;default:default" json:"-"`
+	Name     string `json:"name"`
+	Scope    scope  `json:"scope"`
+	// Hash SHA-256 of the secret
+	Hash      string     `gorm:"uniqueIndex" json:"-"`
+	CreatedOn time.Time  `json:"created_on"`
+	RevokedOn *time.Time `json:"revoked_on,omitempty"`
+	// Secret of the key, only known when the key is issued
+	Secret string `gorm:"-" json:"secret,omitempty"`
+}
+
+// IssueKeyRequest to issue a new API key
+type IssueKeyRequest struct {
+	Name  string `json:"name"`
+	Scope scope  `json:"scope"`
+}
+
+// NewIssueKeyRequest request to issue a key with the scope named s, e.g from the command line
+func NewIssueKeyRequest(name, s string) *IssueKeyRequest {
+	return &IssueKeyRequest{Name: name, Scope: scope(s)}
+}
+
+// issueKeyRules validation rules of a key request
+var issueKeyRules = Rules{
+	{Field: "name", Check: Required, Err: errors.ErrKeyNameIsRequired},
+	{Field: "name", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+	{Field: "scope", Check: OneOf(string(ScopeRead), string(ScopeReadWrite), string(ScopeAdmin)), Err: errors.ErrInvalidScope},
+}
+
+// Rules validation rules of a key request
+func (r *IssueKeyRequest) Rules() Rules {
+	return issueKeyRules
+}
+
+// RevokeKeyRequest to revoke an API key
+type RevokeKeyRequest struct {
+	ID string `json:"id"`
+}
+
+// KeyResponseWrapper response of the key endpoints
+type KeyResponseWrapper struct {
+	Key  *APIKey   `json:"key,omitempty"`
+	Keys []*APIKey `json:"keys,omitempty"`
+	Code int       `json:"-"`
+}
+
+// JSON convert KeyResponseWrapper in json
+func (e *KeyResponseWrapper) JSON() []byte {
+	if e == nil {
+		return []byte("{}")
+	}
+	res, _ := json.Marshal(e)
+	return res
+}
+
+// StatusCode return status code
+func (e *KeyResponseWrapper) StatusCode() int {
+	if e == nil || e.Code == 0 {
+		return http.StatusOK
+	}
+	return e.Code
+}
+
+// Principal authenticated client of a request
+type Principal struct {
+	// Subject who the client is, e.g key:ci
+	Subject string
+	// ID of the client that doesn't change with its name, e.g key:<id of the key>, keeping its
+	// rate limits
+	ID   string
+	Role role
+	// Tenant id of the library the client belongs to, it can't make requests to others
+	Tenant string
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated client of a request
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext authenticated client of a request, nil if the request is anonymous
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
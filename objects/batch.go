@@ -0,0 +1,114 @@
+package objects
+
+import "github.com/redeam/gobooks/errors"
+
+// MaxBatchSize maximum operations in a single batch
+const MaxBatchSize = 1000
+
+// Define enums for batch modes and operations
+type batchMode string
+
+const (
+	// Atomic batches apply every operation or none of them
+	Atomic batchMode = "atomic"
+	// BestEffort batches apply every valid operation independently
+	BestEffort batchMode = "best_effort"
+)
+
+type operation string
+
+const (
+	OpCreate operation = "create"
+	OpUpdate operation = "update"
+	OpDelete operation = "delete"
+	// OpSkip reported for imported records that were left as they are
+	OpSkip operation = "skip"
+)
+
+// BatchOperation single create, update or delete of a batch
+type BatchOperation struct {
+	Op operation `json:"op"`
+	// ID of the Book to update or delete
+	ID string `json:"id,omitempty"`
+	// Book to create, or the details to update
+	Book *Book `json:"book,omitempty"`
+	// expected version of the Book to update or delete, zero skips the check
+	Version int64 `json:"version,omitempty"`
+}
+
+// BatchRequest to create, update and delete many Books at once
+type BatchRequest struct {
+	Mode       batchMode         `json:"mode"`
+	Operations []*BatchOperation `json:"operations"`
+}
+
+// ImportSummary counts of the outcomes of a feed ingestion
+type ImportSummary struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
+
+// BatchResult outcome of a single batch operation or imported row
+type BatchResult struct {
+	// Row line of the imported row
+	Row    int       `json:"row,omitempty"`
+	Op     operation `json:"op"`
+	ID     string    `json:"id,omitempty"`
+	Status int       `json:"status"`
+	Book   *Book     `json:"book,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	// ErrorCode stable code of the error, e.g book_not_found
+	ErrorCode string `json:"error_code,omitempty"`
+	// Errors of each invalid field of the book
+	Errors []*FieldError `json:"errors,omitempty"`
+	// Warnings about imported fields that were left out or only partly kept
+	Warnings []string `json:"warnings,omitempty"`
+	// Err error of the operation, kept to be written in the language of the response
+	Err *errors.Error `json:"-"`
+}
+
+// FieldError error of an invalid field of the book of an operation
+type FieldError struct {
+	Field  string `json:"field"`
+	Code   string `json:"code,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// SetError sets the status and the errors of a failed operation
+func (r *BatchResult) SetError(err *errors.Error) {
+	r.Err = err
+	r.Status, r.Error, r.ErrorCode, r.Errors = err.StatusCode(), err.Message, err.Key, nil
+	for _, f := range err.Fields {
+		r.Errors = append(r.Errors, &FieldError{Field: f.Field, Code: f.Err.Key, Detail: f.Err.Message})
+	}
+}
+
+// batchRules validation rules of a batch, its operations are validated one by one
+var batchRules = Rules{
+	{Field: "mode", Check: OneOf(string(Atomic), string(BestEffort)), Err: errors.ErrInvalidBatchMode},
+	{Field: "operations", Check: Length(1, MaxBatchSize), Err: errors.ErrInvalidBatchSize},
+}
+
+// Rules validation rules of a batch
+func (r *BatchRequest) Rules() Rules {
+	return batchRules
+}
+
+// Normalize defaults the mode of a batch to atomic
+func (r *BatchRequest) Normalize() {
+	if r.Mode == "" {
+		r.Mode = Atomic
+	}
+}
+
+// batchOperationRules validation rules of a batch operation, the book or update of which
+// is validated with its own rules
+var batchOperationRules = Rules{
+	{Field: "op", Check: OneOf(string(OpCreate), string(OpUpdate), string(OpDelete)), Err: errors.ErrInvalidOperation},
+}
+
+// Rules validation rules of a batch operation
+func (op *BatchOperation) Rules() Rules {
+	return batchOperationRules
+}
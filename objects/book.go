@@ -1,7 +1,11 @@
 package objects
 
 import (
+	"strings"
 	"time"
+
+	"github.com/redeam/gobooks/errors"
+	"gorm.io/gorm"
 )
 
 //Define enums for status, rating
@@ -27,6 +31,7 @@ type Book struct {
 	ID string `gorm:"primary_key" json:"id,omitempty"`
 
 	// General details
+	ISBN      string `gorm:"index" json:"isbn,omitempty"`
 	Title     string `json:"title,omitempty"`
 	Author    string `json:"author,omitempty"`
 	Publisher string `json:"publisher,omitempty"`
@@ -35,7 +40,60 @@ type Book struct {
 	Status      status `json:"status,omitempty"`
 	Rating      rating `json:"rating,omitempty"`
 
+	// DueOn when a checked out book is due back, after the loan period of its library
+	DueOn *time.Time `json:"due_on,omitempty"`
+
+	// Location
+	// CallNumber Dewey or LC call number the book is shelved by, e.g 823.914 SMI
+	CallNumber string `json:"call_number,omitempty"`
+	// ShelfKey key of the call number sorting in shelf order
+	ShelfKey string `gorm:"index" json:"-"`
+	// BranchID branch the book is shelved at, empty while it is in transit
+	BranchID   string `gorm:"index" json:"branch_id,omitempty"`
+	LocationID string `json:"location_id,omitempty"`
+	// TransferID transfer of the book to another branch, set while it is in transit
+	TransferID string `json:"transfer_id,omitempty"`
+
 	// Meta information
+	// TenantID library the book belongs to
+	TenantID  string    `gorm:"index;uniqueIndex:idx_books_record_reference,priority:1;not null;default:default" json:"-"`
 	CreatedOn time.Time `json:"created_on,omitempty"`
 	UpdatedOn time.Time `json:"updated_on,omitempty"`
+	// RecordReference reference of the feed record the book was ingested from, e.g an ONIX product,
+	// unique in its library, trash included
+	RecordReference string `gorm:"uniqueIndex:idx_books_record_reference,priority:2,where:record_reference <> ''" json:"record_reference,omitempty"`
+	// Version incremented on every change, used as the ETag of the book
+	Version int64 `gorm:"not null;default:1" json:"version,omitempty"`
+	// DeletedAt set while the book is in the trash
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	// DeletedOn when the book was put in the trash, only written in the list of the trash
+	DeletedOn *time.Time `gorm:"-" json:"deleted_at,omitempty"`
+}
+
+// bookRules validation rules of the general details of a book
+var bookRules = Rules{
+	{Field: "title", Check: Required, Err: errors.ErrTitleandAuthorIsRequired},
+	{Field: "title", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+	{Field: "author", Check: Required, Err: errors.ErrTitleandAuthorIsRequired},
+	{Field: "author", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+	{Field: "publisher", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+	{Field: "status", Check: OneOf(string(CheckedIn), string(CheckedOut)), Err: errors.ErrStatusIsRequired},
+	{Field: "rating", Check: Range(int64(R1), MaxRatingScale), Err: errors.ErrRatingIsRequired},
+	{Field: "isbn", Check: Optional(Format(IsISBN)), Err: errors.ErrInvalidISBN},
+	{Field: "call_number", Check: Optional(Format(IsCallNumber)), Err: errors.ErrInvalidCallNumber},
+}
+
+// Rules validation rules of a book
+func (b *Book) Rules() Rules {
+	return bookRules
+}
+
+// Normalize defaults the status of a book to CheckedIn, and writes its ISBN without separators
+// and its call number without surrounding spaces
+func (b *Book) Normalize() {
+	if b.Status == "" {
+		b.Status = CheckedIn
+	}
+	b.ISBN = normalizedISBN(b.ISBN)
+	b.CallNumber = strings.TrimSpace(b.CallNumber)
 }
@@ -0,0 +1,166 @@
+package objects
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/redeam/gobooks/errors"
+)
+
+// Branch branch of a library, holding its books on the shelves of its locations
+type Branch struct {
+	ID       string `gorm:"primary_key" json:"id"`
+	TenantID string `gorm:"index;not null;default:default" json:"-"`
+	Name     string `json:"name"`
+	Address  string `json:"address,omitempty"`
+	// Meta information
+	CreatedOn time.Time `json:"created_on,omitempty"`
+}
+
+// branchRules validation rules of a branch
+var branchRules = Rules{
+	{Field: "name", Check: Required, Err: errors.ErrBranchNameIsRequired},
+	{Field: "name", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+	{Field: "address", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+}
+
+// Rules validation rules of a branch
+func (b *Branch) Rules() Rules {
+	return branchRules
+}
+
+// Location place of a branch books are shelved at
+type Location struct {
+	ID       string `gorm:"primary_key" json:"id"`
+	TenantID string `gorm:"index;not null;default:default" json:"-"`
+	BranchID string `gorm:"index" json:"branch_id"`
+	Floor    string `json:"floor,omitempty"`
+	Shelf    string `json:"shelf"`
+	// CallNumber call number of the books shelved at the location, e.g 823.914
+	CallNumber string `json:"call_number,omitempty"`
+	// Meta information
+	CreatedOn time.Time `json:"created_on,omitempty"`
+}
+
+// locationRules validation rules of a location
+var locationRules = Rules{
+	{Field: "floor", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+	{Field: "shelf", Check: Required, Err: errors.ErrShelfIsRequired},
+	{Field: "shelf", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+	{Field: "call_number", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+}
+
+// Rules validation rules of a location
+func (l *Location) Rules() Rules {
+	return locationRules
+}
+
+// Define enums for transfer status
+type transferStatus string
+
+const (
+	// TransferInTransit the book has left its branch and not yet reached the other one
+	TransferInTransit transferStatus = "in_transit"
+	// TransferReceived the book has been received by the other branch
+	TransferReceived transferStatus = "received"
+)
+
+// Transfer move of a book from one branch to another, the book is in transit until received
+type Transfer struct {
+	ID       string `gorm:"primary_key" json:"id"`
+	TenantID string `gorm:"index;not null;default:default" json:"-"`
+	BookID   string `gorm:"index" json:"book_id"`
+	// FromBranchID branch the book left, empty when it had no location
+	FromBranchID string `json:"from_branch_id,omitempty"`
+	ToBranchID   string `gorm:"index" json:"to_branch_id"`
+	// ToLocationID where the book is shelved once received, unless another one is given then
+	ToLocationID string         `json:"to_location_id,omitempty"`
+	Status       transferStatus `gorm:"index" json:"status"`
+	SentOn       time.Time      `json:"sent_on"`
+	ReceivedOn   *time.Time     `json:"received_on,omitempty"`
+}
+
+// MoveRequest to shelve a Book at a location, of any branch
+type MoveRequest struct {
+	ID         string `json:"id"`
+	LocationID string `json:"location_id"`
+	// expected version of the book, zero skips the check
+	Version int64 `json:"-"`
+}
+
+// moveRules validation rules of a move
+var moveRules = Rules{
+	{Field: "location_id", Check: Required, Err: errors.ErrLocationIsRequired},
+}
+
+// Rules validation rules of a move
+func (r *MoveRequest) Rules() Rules {
+	return moveRules
+}
+
+// TransferRequest to send a Book to another branch
+type TransferRequest struct {
+	ID         string `json:"id"`
+	ToBranchID string `json:"to_branch_id"`
+	// optional location of the branch the book is shelved at once received
+	ToLocationID string `json:"to_location_id"`
+	// expected version of the book, zero skips the check
+	Version int64 `json:"-"`
+}
+
+// transferRules validation rules of a transfer
+var transferRules = Rules{
+	{Field: "to_branch_id", Check: Required, Err: errors.ErrBranchIsRequired},
+}
+
+// Rules validation rules of a transfer
+func (r *TransferRequest) Rules() Rules {
+	return transferRules
+}
+
+// ReceiveRequest to receive a Book transferred to a branch
+type ReceiveRequest struct {
+	// ID of the transfer
+	ID string `json:"id"`
+	// optional location the book is shelved at, instead of the one of the transfer
+	LocationID string `json:"location_id"`
+}
+
+// ListTransfersRequest for listing the transfers of a library
+type ListTransfersRequest struct {
+	// optional status, e.g in_transit
+	Status string `json:"status"`
+	// optional branch sending or receiving the books
+	BranchID string `json:"branch_id"`
+}
+
+// BranchResponseWrapper response of the branch, location and transfer endpoints
+type BranchResponseWrapper struct {
+	Branch    *Branch     `json:"branch,omitempty"`
+	Branches  []*Branch   `json:"branches,omitempty"`
+	Location  *Location   `json:"location,omitempty"`
+	Locations []*Location `json:"locations,omitempty"`
+	Transfer  *Transfer   `json:"transfer,omitempty"`
+	Transfers []*Transfer `json:"transfers,omitempty"`
+	// Books shelf list of a location
+	Books []*Book `json:"books,omitempty"`
+	Code  int     `json:"-"`
+}
+
+// JSON convert BranchResponseWrapper in json
+func (e *BranchResponseWrapper) JSON() []byte {
+	if e == nil {
+		return []byte("{}")
+	}
+	res, _ := json.Marshal(e)
+	return res
+}
+
+// StatusCode return status code
+func (e *BranchResponseWrapper) StatusCode() int {
+	if e == nil || e.Code == 0 {
+		return http.StatusOK
+	}
+	return e.Code
+}
@@ -0,0 +1,90 @@
+package objects
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Define enums for call number schemes
+type callNumberScheme string
+
+const (
+	// Dewey Dewey Decimal Classification, e.g 823.914 SMI
+	Dewey callNumberScheme = "dewey"
+	// LC Library of Congress Classification, e.g PR6069.M59 W45 2000
+	LC callNumberScheme = "lc"
+)
+
+var (
+	deweyCallNumber = regexp.MustCompile(`^(\d{3})(?:\.(\d+))?(?:\s+(.*))?$`)
+	// LC classes use every letter but I, O, W, X and Y
+	lcCallNumber = regexp.MustCompile(`^([A-HJ-NP-VZ][A-Z]{0,2})\s*(\d{1,4})(?:\.(\d+))?(?:[\s.]+(.*))?$`)
+)
+
+// CallNumber call number split in the parts books are shelved by
+type CallNumber struct {
+	Scheme callNumberScheme
+	// Class letters of an LC call number, empty for Dewey
+	Class string
+	// Number whole and decimal part of the class number
+	Number, Decimal string
+	// Rest cutters, dates and volumes following the class number, e.g G63 K47 2016
+	Rest []string
+}
+
+// ParseCallNumber splits a Dewey or LC call number, reporting whether it is one
+func ParseCallNumber(s string) (*CallNumber, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if m := deweyCallNumber.FindStringSubmatch(s); m != nil {
+		return &CallNumber{Scheme: Dewey, Number: m[1], Decimal: m[2], Rest: callNumberRest(m[3])}, true
+	}
+	if m := lcCallNumber.FindStringSubmatch(s); m != nil {
+		return &CallNumber{Scheme: LC, Class: m[1], Number: m[2], Decimal: m[3], Rest: callNumberRest(m[4])}, true
+	}
+	return nil, false
+}
+
+// callNumberRest cutters and dates of a call number, with the dots introducing cutters dropped
+func callNumberRest(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == '.' })
+}
+
+// IsCallNumber reports whether s is a Dewey or LC call number
+func IsCallNumber(s string) bool {
+	_, ok := ParseCallNumber(s)
+	return ok
+}
+
+// Classification class letters and class number of the call number, e.g QA76.73
+func (c *CallNumber) Classification() string {
+	s := c.Class + c.Number
+	if c.Decimal != "" {
+		s += "." + c.Decimal
+	}
+	return s
+}
+
+// ShelfKey key of the call number sorting byte by byte in shelf order: class letters,
+// then the class number as a number, then the cutters as decimals, e.g QA 0076.73 G63
+func (c *CallNumber) ShelfKey() string {
+	number := c.Number
+	if c.Scheme == LC {
+		number = strings.Repeat("0", 4-len(number)) + number
+	}
+	if c.Decimal != "" {
+		number += "." + c.Decimal
+	}
+	parts := append([]string{number}, c.Rest...)
+	if c.Class != "" {
+		parts = append([]string{c.Class}, parts...)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ShelfKey shelf key of a call number, empty when it isn't a Dewey or LC one
+func ShelfKey(s string) string {
+	if c, ok := ParseCallNumber(s); ok {
+		return c.ShelfKey()
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package objects
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVColumns columns of the CSV export, in order
+var CSVColumns = []string{
+	"id", "isbn", "title", "author", "publisher", "publishdate", "status", "rating",
+	"call_number", "created_on", "updated_on", "version",
+}
+
+// CSVRecord fields of the book in the order of CSVColumns
+func (b *Book) CSVRecord() []string {
+	rating := ""
+	if b.Rating != 0 {
+		rating = strconv.Itoa(int(b.Rating))
+	}
+	return []string{
+		b.ID, b.ISBN, b.Title, b.Author, b.Publisher, b.PublishDate, string(b.Status), rating,
+		b.CallNumber, formatCSVTime(b.CreatedOn), formatCSVTime(b.UpdatedOn), strconv.FormatInt(b.Version, 10),
+	}
+}
+
+// SetCSVField sets the general detail mapped to an imported CSV column, reporting whether
+// the column is known. Identifier and meta information columns are known but ignored,
+// so an export can be imported back.
+func (b *Book) SetCSVField(column, value string) bool {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(strings.TrimSpace(column)) {
+	case "isbn":
+		b.ISBN = value
+	case "title":
+		b.Title = value
+	case "author":
+		b.Author = value
+	case "publisher":
+		b.Publisher = value
+	case "publishdate", "publish_date":
+		b.PublishDate = value
+	case "status":
+		b.Status = status(value)
+	case "rating":
+		// anything but a number is left unset, and reported by the rating check
+		if r, err := strconv.ParseUint(value, 10, 32); err == nil {
+			b.Rating = rating(r)
+		}
+	case "call_number":
+		b.CallNumber = value
+	case "id", "created_on", "updated_on", "version":
+	default:
+		return false
+	}
+	return true
+}
+
+// CSVField general detail mapped to a CSV column as SetCSVField reads it, empty for the
+// ignored and unknown columns
+func (b *Book) CSVField(column string) string {
+	switch strings.ToLower(strings.TrimSpace(column)) {
+	case "isbn":
+		return b.ISBN
+	case "title":
+		return b.Title
+	case "author":
+		return b.Author
+	case "publisher":
+		return b.Publisher
+	case "publishdate", "publish_date":
+		return b.PublishDate
+	case "status":
+		return string(b.Status)
+	case "rating":
+		if b.Rating != 0 {
+			return strconv.Itoa(int(b.Rating))
+		}
+	case "call_number":
+		return b.CallNumber
+	}
+	return ""
+}
+
+func formatCSVTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,48 @@
+package objects
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Define enums for health status
+type healthStatus string
+
+const (
+	// HealthOK the check passed
+	HealthOK healthStatus = "ok"
+	// HealthFailing the check failed, the api can't serve requests
+	HealthFailing healthStatus = "failing"
+)
+
+// HealthCheckResult outcome of a readiness check
+type HealthCheckResult struct {
+	Status healthStatus `json:"status"`
+	// Error why the check failed
+	Error string `json:"error,omitempty"`
+	// DurationMS time the check took, in milliseconds
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// HealthResponseWrapper response of the liveness and readiness endpoints, 503 when failing
+type HealthResponseWrapper struct {
+	Status healthStatus                  `json:"status"`
+	Checks map[string]*HealthCheckResult `json:"checks,omitempty"`
+}
+
+// JSON convert HealthResponseWrapper in json
+func (e *HealthResponseWrapper) JSON() []byte {
+	if e == nil {
+		return []byte("{}")
+	}
+	res, _ := json.Marshal(e)
+	return res
+}
+
+// StatusCode return status code
+func (e *HealthResponseWrapper) StatusCode() int {
+	if e == nil || e.Status != HealthFailing {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
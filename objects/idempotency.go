@@ -0,0 +1,33 @@
+package objects
+
+import (
+	"time"
+)
+
+// DefaultIdempotencyRetention how long the responses of requests with an Idempotency-Key are
+// kept, and replayed to the retries of the requests
+const DefaultIdempotencyRetention = 24 * time.Hour
+
+// IdempotencyKeyLease how long a request with an Idempotency-Key holds its key while it is being
+// made. Keys held longer were left by servers that went away, and can be used again
+const IdempotencyKeyLease = 5 * time.Minute
+
+// MaxIdempotencyKeyLength maximum length of an Idempotency-Key
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyKey request made with an Idempotency-Key by a client of a library, and its response
+type IdempotencyKey struct {
+	TenantID string `gorm:"primary_key" json:"-"`
+	// Client subject of the client, ip:<address> without credentials
+	Client string `gorm:"primary_key" json:"client"`
+	Key    string `gorm:"primary_key" json:"key"`
+	// Fingerprint hash of the method, path and body of the request, retries must have the same
+	Fingerprint string `json:"fingerprint"`
+	// Response, Status is zero while the request is being made
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	ETag        string `gorm:"column:etag" json:"etag"`
+	Body        []byte `json:"-"`
+	// Meta information
+	CreatedOn time.Time `gorm:"index" json:"created_on"`
+}
@@ -0,0 +1,53 @@
+package objects
+
+import (
+	"strings"
+)
+
+// NormalizeISBN strips hyphens and spaces from an ISBN-10 or ISBN-13,
+// reporting whether its check digit is valid
+func NormalizeISBN(isbn string) (string, bool) {
+	isbn = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+	switch len(isbn) {
+	case 10:
+		sum := 0
+		for i, c := range isbn {
+			d := int(c - '0')
+			if c == 'X' && i == 9 {
+				d = 10
+			} else if c < '0' || c > '9' {
+				return isbn, false
+			}
+			sum += (10 - i) * d
+		}
+		return isbn, sum%11 == 0
+	case 13:
+		sum := 0
+		for i, c := range isbn {
+			if c < '0' || c > '9' {
+				return isbn, false
+			}
+			w := 1
+			if i%2 == 1 {
+				w = 3
+			}
+			sum += w * int(c-'0')
+		}
+		return isbn, sum%10 == 0
+	}
+	return isbn, false
+}
+
+// IsISBN reports whether s is a valid ISBN-10 or ISBN-13
+func IsISBN(s string) bool {
+	_, ok := NormalizeISBN(s)
+	return ok
+}
+
+// normalizedISBN ISBN without separators, invalid ones are kept as they are
+func normalizedISBN(s string) string {
+	if isbn, ok := NormalizeISBN(s); ok {
+		return isbn
+	}
+	return s
+}
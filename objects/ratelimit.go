@@ -0,0 +1,78 @@
+package objects
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit number of requests a client can make per period, in bursts of up to Requests.
+// Zero requests means no limit
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseRateLimit parses a limit of the form requests/period, e.g 600/1m, empty for no limit
+func ParseRateLimit(s string) (RateLimit, error) {
+	if s == "" {
+		return RateLimit{}, nil
+	}
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, fmt.Errorf("rate limit %q isn't of the form requests/period, e.g 600/1m", s)
+	}
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || n < 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q: invalid number of requests", s)
+	}
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q: invalid period", s)
+	}
+	return RateLimit{Requests: n, Period: period}, nil
+}
+
+// RateBucket token bucket of a client, refilled at the rate of its limit
+type RateBucket struct {
+	// Key client and kind of requests the bucket limits
+	Key       string `gorm:"primary_key"`
+	Tokens    float64
+	UpdatedOn time.Time `gorm:"index"`
+}
+
+// RateResult outcome of taking a token from a bucket
+type RateResult struct {
+	Allowed   bool
+	Limit     RateLimit
+	Remaining int
+	// Reset time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter time until the next token, zero when the request is allowed
+	RetryAfter time.Duration
+}
+
+// Take takes a token from the bucket at now, after refilling it for the time elapsed since it
+// was last updated. New buckets are full
+func (b *RateBucket) Take(l RateLimit, now time.Time) *RateResult {
+	burst := float64(l.Requests)
+	every := l.Period / time.Duration(l.Requests)
+	if b.UpdatedOn.IsZero() {
+		b.Tokens = burst
+	} else if elapsed := now.Sub(b.UpdatedOn); elapsed > 0 {
+		b.Tokens = math.Min(burst, b.Tokens+float64(elapsed)/float64(every))
+	}
+	b.UpdatedOn = now
+	res := &RateResult{Limit: l}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.Tokens) * float64(every))
+	}
+	res.Remaining = int(b.Tokens)
+	res.Reset = time.Duration((burst - b.Tokens) * float64(every))
+	return res
+}
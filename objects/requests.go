@@ -3,11 +3,32 @@ package objects
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redeam/gobooks/errors"
 )
 
 // MaxListLimit maximum listting
 const MaxListLimit = 200
 
+// DefaultTrashRetention how long deleted books stay in the trash before being purged
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// Media types accepted by PATCH requests
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// Orders of listed Books
+const (
+	// SortID order the Books were created in, the default
+	SortID = "id"
+	// SortShelf order of the Books on the shelves, by call number
+	SortShelf = "shelf"
+)
+
 // GetRequest for retrieving single Book
 type GetRequest struct {
 	ID string `json:"id"`
@@ -18,6 +39,18 @@ type ListRequest struct {
 	Limit int `json:"limit"`
 	// optional title matching
 	Title string `json:"title"`
+	// optional exact ISBN
+	ISBN string `json:"isbn"`
+	// optional exact feed record reference
+	RecordReference string `json:"record_reference"`
+	// optional, lists the Books in the trash as well, with when they were put there
+	WithTrash bool `json:"-"`
+	// optional branch the Books are shelved at
+	Branch string `json:"branch"`
+	// optional id to list the Books after, for paging through the whole catalog
+	After string `json:"after"`
+	// optional order of the Books, SortID or SortShelf
+	Sort string `json:"sort"`
 }
 
 // CreateRequest for creating a new Book
@@ -28,24 +61,79 @@ type CreateRequest struct {
 // UpdateDetailsRequest to update existing Book
 type UpdateDetailsRequest struct {
 	ID          string `json:"id"`
+	ISBN        string `json:"isbn"`
 	Title       string `json:"title"`
 	Author      string `json:"author"`
 	Publisher   string `json:"publisher"`
 	PublishDate string `json:"publishdate"`
 	Status      status `json:"status"`
 	Rating      rating `json:"rating"`
+	CallNumber  string `json:"call_number"`
+	// expected version of the book, zero skips the check
+	Version int64 `json:"-"`
+}
+
+// updateDetailsRules validation rules of a book update, those of a book with its id
+var updateDetailsRules = Rules{
+	{Field: "id", Check: Required, Err: errors.ErrValidBookIdIsRequired},
+	{Field: "title", Check: Required, Err: errors.ErrTitleandAuthorIsRequired},
+	{Field: "title", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+	{Field: "author", Check: Required, Err: errors.ErrTitleandAuthorIsRequired},
+	{Field: "author", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+	{Field: "publisher", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+	{Field: "status", Check: Optional(OneOf(string(CheckedIn), string(CheckedOut))), Err: errors.ErrStatusIsRequired},
+	{Field: "rating", Check: Range(int64(R1), MaxRatingScale), Err: errors.ErrRatingIsRequired},
+	{Field: "isbn", Check: Optional(Format(IsISBN)), Err: errors.ErrInvalidISBN},
+	{Field: "call_number", Check: Optional(Format(IsCallNumber)), Err: errors.ErrInvalidCallNumber},
+}
+
+// Rules validation rules of a book update
+func (r *UpdateDetailsRequest) Rules() Rules {
+	return updateDetailsRules
+}
+
+// Normalize writes the ISBN of a book update without separators, and its call number
+// without surrounding spaces
+func (r *UpdateDetailsRequest) Normalize() {
+	r.ISBN = normalizedISBN(r.ISBN)
+	r.CallNumber = strings.TrimSpace(r.CallNumber)
+}
+
+// UpdateRequest to replace all general details of an existing Book,
+// the Book version is checked when it isn't zero
+type UpdateRequest struct {
+	Book *Book `json:"book"`
 }
 
 // DeleteRequest to delete a Book
 type DeleteRequest struct {
 	ID string `json:"id"`
+	// expected version of the book, zero skips the check
+	Version int64 `json:"-"`
+}
+
+// RestoreRequest to restore a Book from the trash
+type RestoreRequest struct {
+	ID string `json:"id"`
+}
+
+// PurgeRequest to permanently remove Books deleted before a point in time
+type PurgeRequest struct {
+	Before time.Time `json:"before"`
 }
 
 // BookResponseWrapper reponse of any Book request
 type BookResponseWrapper struct {
-	Book  *Book   `json:"book,omitempty"`
-	Books []*Book `json:"books,omitempty"`
-	Code  int     `json:"-"`
+	Book   *Book   `json:"book,omitempty"`
+	Books  []*Book `json:"books,omitempty"`
+	Purged int64   `json:"purged,omitempty"`
+	// History of changes made to a Book
+	History []*AuditEvent `json:"history,omitempty"`
+	// Results of each operation of a batch
+	Results []*BatchResult `json:"results,omitempty"`
+	// Summary of a feed ingestion
+	Summary *ImportSummary `json:"summary,omitempty"`
+	Code    int            `json:"-"`
 }
 
 // JSON convert BookResponseWrapper in json
@@ -0,0 +1,112 @@
+package objects
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/redeam/gobooks/errors"
+)
+
+// DefaultTenantID id of the library of requests that don't name one, and of the deployment's
+// operators provisioning the others
+const DefaultTenantID = "default"
+
+// MaxRatingScale highest rating a library can use for its books
+const MaxRatingScale = 10
+
+// DefaultTenant library of requests that don't name one
+var DefaultTenant = &Tenant{ID: DefaultTenantID, Name: "Default", LoanPeriodDays: 21, MaxRating: int(R3)}
+
+// Tenant independent library hosted on the deployment, with its own books, keys and settings
+type Tenant struct {
+	// ID of the library, also its subdomain, e.g "springfield"
+	ID   string `gorm:"primary_key" json:"id"`
+	Name string `json:"name"`
+	// LoanPeriodDays how long books are checked out for
+	LoanPeriodDays int `json:"loan_period_days"`
+	// MaxRating highest rating of books, which are rated from 1
+	MaxRating int       `json:"max_rating"`
+	CreatedOn time.Time `json:"created_on,omitempty"`
+	UpdatedOn time.Time `json:"updated_on,omitempty"`
+}
+
+// tenantIDPattern ids usable as DNS labels
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// tenantRules validation rules of a library
+var tenantRules = Rules{
+	{Field: "id", Check: Format(tenantIDPattern.MatchString), Err: errors.ErrInvalidTenantID},
+	{Field: "name", Check: Required, Err: errors.ErrTenantNameIsRequired},
+	{Field: "name", Check: Length(0, MaxTextLength), Err: errors.ErrFieldTooLong},
+	{Field: "loan_period_days", Check: Range(1, 365), Err: errors.ErrInvalidLoanPeriod},
+	{Field: "max_rating", Check: Range(1, MaxRatingScale), Err: errors.ErrInvalidRatingScale},
+}
+
+// Rules validation rules of a library
+func (t *Tenant) Rules() Rules {
+	return tenantRules
+}
+
+// Normalize defaults the settings of a library to those of the default one
+func (t *Tenant) Normalize() {
+	if t.LoanPeriodDays == 0 {
+		t.LoanPeriodDays = DefaultTenant.LoanPeriodDays
+	}
+	if t.MaxRating == 0 {
+		t.MaxRating = DefaultTenant.MaxRating
+	}
+}
+
+// BookRules validation rules of the books of the library, on top of those of their type
+func (t *Tenant) BookRules() Rules {
+	return Rules{
+		{Field: "rating", Check: Range(int64(R1), int64(t.MaxRating)), Err: errors.ErrRatingIsRequired},
+	}
+}
+
+// LoanPeriod how long books are checked out for
+func (t *Tenant) LoanPeriod() time.Duration {
+	return time.Duration(t.LoanPeriodDays) * 24 * time.Hour
+}
+
+// TenantResponseWrapper response of the library endpoints
+type TenantResponseWrapper struct {
+	Tenant  *Tenant   `json:"tenant,omitempty"`
+	Tenants []*Tenant `json:"tenants,omitempty"`
+	Code    int       `json:"-"`
+}
+
+// JSON convert TenantResponseWrapper in json
+func (e *TenantResponseWrapper) JSON() []byte {
+	if e == nil {
+		return []byte("{}")
+	}
+	res, _ := json.Marshal(e)
+	return res
+}
+
+// StatusCode return status code
+func (e *TenantResponseWrapper) StatusCode() int {
+	if e == nil || e.Code == 0 {
+		return http.StatusOK
+	}
+	return e.Code
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context carrying the library a request is made to
+func WithTenant(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, t)
+}
+
+// TenantFromContext library a request is made to, DefaultTenant if unknown
+func TenantFromContext(ctx context.Context) *Tenant {
+	if t, ok := ctx.Value(tenantKey{}).(*Tenant); ok {
+		return t
+	}
+	return DefaultTenant
+}
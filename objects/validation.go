@@ -0,0 +1,123 @@
+package objects
+
+import (
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/redeam/gobooks/errors"
+)
+
+// MaxTextLength maximum length of the free text fields of a book
+const MaxTextLength = 255
+
+// Validatable object with validation rules, declared once for its type
+type Validatable interface {
+	Rules() Rules
+}
+
+// Normalizer object setting its defaults and canonical forms before it is validated
+type Normalizer interface {
+	Normalize()
+}
+
+// Rules validation rules of a type, checked in order
+type Rules []*Rule
+
+// Rule check of a field, named as in JSON, and the error reported when it fails
+type Rule struct {
+	Field string
+	Check Check
+	Err   *errors.Error
+}
+
+// Check checks the value of a field
+type Check func(v reflect.Value) bool
+
+// Validate normalizes an object and checks it against the rules of its type then the extra
+// rules, e.g those of a library, reporting the first failing rule of every invalid field
+func Validate(v Validatable, extra ...*Rule) error {
+	if n, ok := v.(Normalizer); ok {
+		n.Normalize()
+	}
+	obj := reflect.Indirect(reflect.ValueOf(v))
+	var fields []*errors.FieldError
+	failed := map[string]bool{}
+	rules := append(append(Rules{}, v.Rules()...), extra...)
+	for _, rule := range rules {
+		if failed[rule.Field] || rule.Check(field(obj, rule.Field)) {
+			continue
+		}
+		failed[rule.Field] = true
+		fields = append(fields, &errors.FieldError{Field: rule.Field, Err: rule.Err})
+	}
+	return errors.Invalid(fields...)
+}
+
+// field value of the struct field with the given JSON name
+func field(obj reflect.Value, name string) reflect.Value {
+	t := obj.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; tag == name {
+			return obj.Field(i)
+		}
+	}
+	panic("objects: " + t.Name() + " has no field " + name)
+}
+
+// Required checks the field isn't empty
+func Required(v reflect.Value) bool {
+	return !v.IsZero()
+}
+
+// Optional checks empty fields are valid, and others pass check
+func Optional(check Check) Check {
+	return func(v reflect.Value) bool {
+		return v.IsZero() || check(v)
+	}
+}
+
+// OneOf checks the field is one of the values
+func OneOf(values ...string) Check {
+	return func(v reflect.Value) bool {
+		for _, value := range values {
+			if v.String() == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Range checks a number is between min and max, included
+func Range(min, max int64) Check {
+	return func(v reflect.Value) bool {
+		var n int64
+		switch v.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = int64(v.Uint())
+		default:
+			n = v.Int()
+		}
+		return n >= min && n <= max
+	}
+}
+
+// Length checks the number of characters of a string, or of items of a list, is between
+// min and max, included
+func Length(min, max int) Check {
+	return func(v reflect.Value) bool {
+		n := v.Len()
+		if v.Kind() == reflect.String {
+			n = utf8.RuneCountInString(v.String())
+		}
+		return n >= min && n <= max
+	}
+}
+
+// Format checks a string has a valid format
+func Format(valid func(s string) bool) Check {
+	return func(v reflect.Value) bool {
+		return valid(v.String())
+	}
+}
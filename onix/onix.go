@@ -0,0 +1,211 @@
+// Package onix reads the products of ONIX for Books 3.0 feeds, with reference or short
+// tag names, and maps them to Books.
+package onix
+
+import (
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/redeam/gobooks/objects"
+)
+
+// Notification types of a product record
+const (
+	NotificationEarly     = "01"
+	NotificationAdvance   = "02"
+	NotificationConfirmed = "03"
+	NotificationDelete    = "05"
+)
+
+// Product ONIX product record, limited to the composites mapped to a Book
+type Product struct {
+	RecordReference    string               `xml:"RecordReference"`
+	NotificationType   string               `xml:"NotificationType"`
+	ProductIdentifiers []*ProductIdentifier `xml:"ProductIdentifier"`
+	Titles             []*TitleDetail       `xml:"DescriptiveDetail>TitleDetail"`
+	Contributors       []*Contributor       `xml:"DescriptiveDetail>Contributor"`
+	Publishers         []*Publisher         `xml:"PublishingDetail>Publisher"`
+	PublishingDates    []*PublishingDate    `xml:"PublishingDetail>PublishingDate"`
+}
+
+// ProductIdentifier identifier of a product, such as its ISBN
+type ProductIdentifier struct {
+	ProductIDType string `xml:"ProductIDType"`
+	IDValue       string `xml:"IDValue"`
+}
+
+// TitleDetail title of a product
+type TitleDetail struct {
+	TitleType     string          `xml:"TitleType"`
+	TitleElements []*TitleElement `xml:"TitleElement"`
+}
+
+// TitleElement part of a title, at product or collection level
+type TitleElement struct {
+	TitleElementLevel  string `xml:"TitleElementLevel"`
+	TitleText          string `xml:"TitleText"`
+	TitlePrefix        string `xml:"TitlePrefix"`
+	TitleWithoutPrefix string `xml:"TitleWithoutPrefix"`
+	Subtitle           string `xml:"Subtitle"`
+}
+
+// Contributor person or corporate body contributing to a product
+type Contributor struct {
+	SequenceNumber     int      `xml:"SequenceNumber"`
+	ContributorRoles   []string `xml:"ContributorRole"`
+	PersonName         string   `xml:"PersonName"`
+	NamesBeforeKey     string   `xml:"NamesBeforeKey"`
+	KeyNames           string   `xml:"KeyNames"`
+	CorporateName      string   `xml:"CorporateName"`
+	PersonNameInverted string   `xml:"PersonNameInverted"`
+}
+
+// Publisher publisher of a product
+type Publisher struct {
+	PublishingRole string `xml:"PublishingRole"`
+	PublisherName  string `xml:"PublisherName"`
+}
+
+// PublishingDate date of a product, such as its publication date
+type PublishingDate struct {
+	PublishingDateRole string `xml:"PublishingDateRole"`
+	Date               string `xml:"Date"`
+}
+
+// code lists values used by the mapping
+const (
+	idTypeISBN10        = "02"
+	idTypeISBN13        = "15"
+	titleTypeDistinct   = "01"
+	titleLevelProduct   = "01"
+	roleAuthor          = "A01"
+	publishingRoleMain  = "01"
+	dateRolePublication = "01"
+)
+
+// ReadProducts reads the products of an ONIX message one by one, calling fn with each
+func ReadProducts(r io.Reader, fn func(p *Product) error) error {
+	dec := xml.NewTokenDecoder(&referenceNames{dec: xml.NewDecoder(r)})
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "Product" {
+			continue
+		}
+		p := &Product{}
+		if err := dec.DecodeElement(p, &start); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+}
+
+// ToBook maps a product to a Book, reporting the details that were missing or only partly kept
+func (p *Product) ToBook() (*objects.Book, []string) {
+	bk := &objects.Book{RecordReference: p.RecordReference}
+	var report []string
+
+	for _, id := range p.ProductIdentifiers {
+		if id.ProductIDType == idTypeISBN13 || (id.ProductIDType == idTypeISBN10 && bk.ISBN == "") {
+			bk.ISBN = strings.TrimSpace(id.IDValue)
+		}
+	}
+
+	for _, t := range p.Titles {
+		if t.TitleType != titleTypeDistinct {
+			continue
+		}
+		for _, e := range t.TitleElements {
+			if e.TitleElementLevel != titleLevelProduct && e.TitleElementLevel != "" {
+				continue
+			}
+			bk.Title = strings.TrimSpace(e.TitleText)
+			if bk.Title == "" {
+				bk.Title = strings.TrimSpace(e.TitlePrefix + " " + e.TitleWithoutPrefix)
+			}
+			if sub := strings.TrimSpace(e.Subtitle); sub != "" {
+				bk.Title += ": " + sub
+			}
+		}
+	}
+	if bk.Title == "" {
+		report = append(report, "TitleDetail: no distinctive product title")
+	}
+
+	var authors []*Contributor
+	others := 0
+	for _, c := range p.Contributors {
+		if c.hasRole(roleAuthor) {
+			authors = append(authors, c)
+		} else {
+			others++
+		}
+	}
+	sort.SliceStable(authors, func(i, j int) bool {
+		return authors[i].SequenceNumber < authors[j].SequenceNumber
+	})
+	names := make([]string, 0, len(authors))
+	for _, c := range authors {
+		if name := c.name(); name != "" {
+			names = append(names, name)
+		}
+	}
+	bk.Author = strings.Join(names, ", ")
+	if others > 0 {
+		report = append(report, "Contributor: only authors (A01) are kept")
+	}
+
+	for _, pub := range p.Publishers {
+		if pub.PublishingRole == publishingRoleMain || bk.Publisher == "" {
+			bk.Publisher = strings.TrimSpace(pub.PublisherName)
+		}
+	}
+	for _, d := range p.PublishingDates {
+		if d.PublishingDateRole == dateRolePublication {
+			bk.PublishDate = formatDate(strings.TrimSpace(d.Date))
+		}
+	}
+	return bk, report
+}
+
+func (c *Contributor) hasRole(role string) bool {
+	for _, r := range c.ContributorRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Contributor) name() string {
+	switch {
+	case c.PersonName != "":
+		return strings.TrimSpace(c.PersonName)
+	case c.KeyNames != "":
+		return strings.TrimSpace(c.NamesBeforeKey + " " + c.KeyNames)
+	case c.CorporateName != "":
+		return strings.TrimSpace(c.CorporateName)
+	}
+	return strings.TrimSpace(c.PersonNameInverted)
+}
+
+// formatDate formats YYYYMMDD and YYYYMM dates as YYYY-MM-DD and YYYY-MM, others are kept as is
+func formatDate(d string) string {
+	switch len(d) {
+	case 8:
+		return d[:4] + "-" + d[4:6] + "-" + d[6:]
+	case 6:
+		return d[:4] + "-" + d[4:]
+	}
+	return d
+}
@@ -0,0 +1,68 @@
+package onix
+
+import (
+	"encoding/xml"
+)
+
+// reference names of the short tags of the mapped elements
+var shortTags = map[string]string{
+	"product":           "Product",
+	"a001":              "RecordReference",
+	"a002":              "NotificationType",
+	"productidentifier": "ProductIdentifier",
+	"b221":              "ProductIDType",
+	"b244":              "IDValue",
+	"descriptivedetail": "DescriptiveDetail",
+	"titledetail":       "TitleDetail",
+	"b202":              "TitleType",
+	"titleelement":      "TitleElement",
+	"x409":              "TitleElementLevel",
+	"b203":              "TitleText",
+	"b030":              "TitlePrefix",
+	"b031":              "TitleWithoutPrefix",
+	"b029":              "Subtitle",
+	"contributor":       "Contributor",
+	"b034":              "SequenceNumber",
+	"b035":              "ContributorRole",
+	"b036":              "PersonName",
+	"b037":              "PersonNameInverted",
+	"b039":              "NamesBeforeKey",
+	"b040":              "KeyNames",
+	"b047":              "CorporateName",
+	"publishingdetail":  "PublishingDetail",
+	"publisher":         "Publisher",
+	"b291":              "PublishingRole",
+	"b081":              "PublisherName",
+	"publishingdate":    "PublishingDate",
+	"x448":              "PublishingDateRole",
+	"b306":              "Date",
+}
+
+// referenceNames token reader renaming short tags to their reference names,
+// and dropping namespaces so both ONIX namespaces decode alike
+type referenceNames struct {
+	dec *xml.Decoder
+}
+
+func (rn *referenceNames) Token() (xml.Token, error) {
+	tok, err := rn.dec.Token()
+	if err != nil {
+		return tok, err
+	}
+	switch t := tok.(type) {
+	case xml.StartElement:
+		t.Name = referenceName(t.Name)
+		return t, nil
+	case xml.EndElement:
+		t.Name = referenceName(t.Name)
+		return t, nil
+	}
+	return tok, nil
+}
+
+func referenceName(n xml.Name) xml.Name {
+	if ref, ok := shortTags[n.Local]; ok {
+		return xml.Name{Local: ref}
+	}
+	return xml.Name{Local: n.Local}
+}
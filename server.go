@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/redeam/gobooks/auth"
 	"github.com/redeam/gobooks/handlers"
 	"github.com/redeam/gobooks/store"
 )
@@ -17,43 +25,243 @@ type Args struct {
 	// port for the server of the form,
 	// e.g ":8080"
 	port string
+	// OIDC provider whose bearer tokens are accepted, none when its JWKS URL is empty
+	oidc auth.OIDCConfig
+	// path of the access policy file, the default policy when empty
+	policy string
+	// domain whose subdomains name libraries,
+	// e.g "books.example.com" for springfield.books.example.com
+	domain string
+	// options of the book handlers
+	books handlers.BookHandlerConfig
+	// limits of the requests of each client
+	rateLimit handlers.RateLimitConfig
+	// where the rate limits are kept, "memory" for each replica on its own or "postgres" for
+	// all replicas sharing the database
+	rateStore string
+	// timeouts of the server, zero for none
+	timeouts Timeouts
+}
+
+// Timeouts timeouts of the server, e.g so slow clients can't hold connections
+type Timeouts struct {
+	// ReadHeader time to read the headers of a request, Read to read the whole request
+	ReadHeader, Read time.Duration
+	// Write time from the end of the request headers to the end of the response, so it must be
+	// long enough for the largest exports
+	Write time.Duration
+	// Idle time to wait for the next request on a keep-alive connection
+	Idle time.Duration
+	// Unready time the server keeps serving requests once asked to stop, while its readiness
+	// fails so load balancers stop sending it requests
+	Unready time.Duration
+	// Drain time given to the requests being made to end, once the server stops accepting them
+	Drain time.Duration
 }
 
 // Run run the server based on given args
 func Run(args Args) error {
 	// router
-	router := mux.NewRouter().
+	root := mux.NewRouter()
+	router := root.
 		PathPrefix("/api/v1/"). // add prefix for v1 api `/api/v1/`
 		Subrouter()
 
 	st := store.NewPostgresBookStore(args.conn)
-	hnd := handlers.NewBookHandler(st)
-	RegisterAllRoutes(router, hnd)
+	defer closeStore(st)
+	keys := store.NewPostgresKeyStore(args.conn)
+	defer closeStore(keys)
+	tenants := store.NewPostgresTenantStore(args.conn)
+	defer closeStore(tenants)
+	hnd := handlers.NewBookHandler(st, args.books)
+	authenticators := []auth.Authenticator{auth.APIKeys(keys)}
+	if args.oidc.JWKSURL != "" {
+		authenticators = append(authenticators, auth.Bearer(args.oidc))
+	}
+	policy := auth.DefaultPolicy()
+	if args.policy != "" {
+		var err error
+		if policy, err = auth.LoadPolicy(args.policy); err != nil {
+			return err
+		}
+	}
+	var rates store.IRateStore
+	switch args.rateStore {
+	case "", "memory":
+		rates = store.NewMemoryRateStore()
+	case "postgres":
+		rates = store.NewPostgresRateStore(args.conn)
+	default:
+		return fmt.Errorf("unknown rate limit store %q, should be memory or postgres", args.rateStore)
+	}
+	defer closeStore(rates)
+	RegisterAllRoutes(router, hnd, handlers.AddressRateLimit(rates, args.rateLimit), handlers.ResolveTenant(tenants, args.domain),
+		auth.Middleware(policy, authenticators...), handlers.RateLimit(rates, args.rateLimit))
+	RegisterKeyRoutes(router, handlers.NewKeyHandler(keys))
+	RegisterTenantRoutes(router, handlers.NewTenantHandler(tenants))
+	for _, route := range policy.Missing(router) {
+		log.Println("No role is allowed on", route)
+	}
+	health := handlers.NewHealthHandler(
+		handlers.HealthCheck{Name: "database", Check: st.Ping},
+		handlers.HealthCheck{Name: "migrations", Check: func(ctx context.Context) error {
+			for _, s := range []store.IHealthStore{st, keys, tenants, rates} {
+				if err := s.Migrated(ctx); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+	)
+	RegisterHealthRoutes(root, health)
 
 	// start server
-	log.Println("Starting server at port: ", args.port)
-	return http.ListenAndServe(args.port, router)
+	srv := &http.Server{
+		Addr:              args.port,
+		Handler:           root,
+		ReadHeaderTimeout: args.timeouts.ReadHeader,
+		ReadTimeout:       args.timeouts.Read,
+		WriteTimeout:      args.timeouts.Write,
+		IdleTimeout:       args.timeouts.Idle,
+	}
+	stopped, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	served := make(chan error, 1)
+	go func() {
+		log.Println("Starting server at port: ", args.port)
+		served <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-served:
+		return err
+	case <-stopped.Done():
+	}
+	// a second signal kills the server right away
+	stop()
+	health.Drain()
+	log.Println("Failing readiness for", args.timeouts.Unready, "before stopping the server")
+	time.Sleep(args.timeouts.Unready)
+	return shutdown(srv, args.timeouts.Drain)
+}
+
+// shutdown stops the server accepting requests, and waits up to drain for the requests being made
+// to end before closing their connections
+func shutdown(srv *http.Server, drain time.Duration) error {
+	log.Println("Stopping server, draining requests for up to", drain)
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Requests still being made after the drain period:", err)
+		return srv.Close()
+	}
+	log.Println("Server stopped")
+	return nil
 }
 
-// RegisterAllRoutes registers all routes of the api
-func RegisterAllRoutes(router *mux.Router, hnd handlers.IBookHandler) {
+// closeStore closes the connections of a store once the server is stopped
+func closeStore(st io.Closer) {
+	if err := st.Close(); err != nil {
+		log.Println(err)
+	}
+}
+
+// RegisterAllRoutes registers all routes of the api, named after their permission in the access
+// policy. Requests go through the guards before the handlers, e.g to resolve their library,
+// authenticate and authorize them then limit their rate; without any every request is allowed, to
+// the default library
+func RegisterAllRoutes(router *mux.Router, hnd handlers.IBookHandler, guards ...mux.MiddlewareFunc) {
+
+	// set content type, negotiated from the Accept header
+	router.Use(handlers.Negotiate)
 
-	// set content type
-	router.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			next.ServeHTTP(w, r)
-		})
-	})
+	// resolve the library, authenticate clients, check their role and limit their rate
+	router.Use(guards...)
 
 	// get books
-	router.HandleFunc("/books", hnd.Get).Methods(http.MethodGet)
+	router.HandleFunc("/books", hnd.Get).Methods(http.MethodGet).Name("getBook")
 	// create books
-	router.HandleFunc("/books", hnd.Create).Methods(http.MethodPost)
+	router.HandleFunc("/books", hnd.Create).Methods(http.MethodPost).Name("createBook")
+	// create, update and delete many books
+	router.HandleFunc("/books/batch", hnd.Batch).Methods(http.MethodPost).Name("batchBooks")
 	// delete book
-	router.HandleFunc("/books", hnd.Delete).Methods(http.MethodDelete)
+	router.HandleFunc("/books", hnd.Delete).Methods(http.MethodDelete).Name("deleteBook")
 	// update book details
-	router.HandleFunc("/books/update", hnd.UpdateDetails).Methods(http.MethodPut)
+	router.HandleFunc("/books/update", hnd.UpdateDetails).Methods(http.MethodPut).Name("updateBook")
+	// partially update book
+	router.HandleFunc("/books/{id}", hnd.Patch).Methods(http.MethodPatch).Name("patchBook")
 	// list books
-	router.HandleFunc("/books/list", hnd.List).Methods(http.MethodGet)
+	router.HandleFunc("/books/list", hnd.List).Methods(http.MethodGet).Name("listBooks")
+	// stream books as NDJSON
+	router.HandleFunc("/books/stream", hnd.Stream).Methods(http.MethodGet).Name("streamBooks")
+	// export books as csv
+	router.HandleFunc("/books/export.csv", hnd.Export).Methods(http.MethodGet).Name("exportCSV")
+	// import books from csv
+	router.HandleFunc("/books/import", hnd.Import).Methods(http.MethodPost).Name("importCSV")
+	// export books as MARC 21 or MARCXML
+	router.HandleFunc("/books/export.marc", hnd.ExportMARC).Methods(http.MethodGet).Name("exportMARC")
+	// import books from MARC 21 or MARCXML
+	router.HandleFunc("/books/import/marc", hnd.ImportMARC).Methods(http.MethodPost).Name("importMARC")
+	// ingest an ONIX for Books feed
+	router.HandleFunc("/books/import/onix", hnd.ImportONIX).Methods(http.MethodPost).Name("importONIX")
+	// list deleted books
+	router.HandleFunc("/books/trash", hnd.Trash).Methods(http.MethodGet).Name("listTrash")
+	// history of book changes
+	router.HandleFunc("/books/{id}/history", hnd.History).Methods(http.MethodGet).Name("bookHistory")
+	// revert book to a previous revision
+	router.HandleFunc("/books/{id}/revert", hnd.Revert).Methods(http.MethodPost).Name("revertBook")
+	// restore deleted book
+	router.HandleFunc("/books/{id}/restore", hnd.Restore).Methods(http.MethodPost).Name("restoreBook")
+	// permanently remove books deleted before the retention period
+	router.HandleFunc("/admin/books/purge", hnd.Purge).Methods(http.MethodPost).Name("purgeTrash")
+	// create branch
+	router.HandleFunc("/branches", hnd.CreateBranch).Methods(http.MethodPost).Name("createBranch")
+	// list branches
+	router.HandleFunc("/branches", hnd.ListBranches).Methods(http.MethodGet).Name("listBranches")
+	// create location of a branch
+	router.HandleFunc("/branches/{id}/locations", hnd.CreateLocation).Methods(http.MethodPost).Name("createLocation")
+	// list locations of a branch
+	router.HandleFunc("/branches/{id}/locations", hnd.ListLocations).Methods(http.MethodGet).Name("listLocations")
+	// books of a location in shelf order
+	router.HandleFunc("/locations/{id}/shelflist", hnd.ShelfList).Methods(http.MethodGet).Name("shelfList")
+	// shelve book at a location
+	router.HandleFunc("/books/{id}/location", hnd.Move).Methods(http.MethodPut).Name("moveBook")
+	// send book to another branch
+	router.HandleFunc("/books/{id}/transfer", hnd.Transfer).Methods(http.MethodPost).Name("transferBook")
+	// list transfers between branches
+	router.HandleFunc("/transfers", hnd.ListTransfers).Methods(http.MethodGet).Name("listTransfers")
+	// receive transferred book
+	router.HandleFunc("/transfers/{id}/receive", hnd.Receive).Methods(http.MethodPost).Name("receiveTransfer")
+}
+
+// RegisterKeyRoutes registers the routes managing API keys
+func RegisterKeyRoutes(router *mux.Router, hnd handlers.IKeyHandler) {
+	// issue API key
+	router.HandleFunc("/admin/keys", hnd.Issue).Methods(http.MethodPost).Name("issueKey")
+	// list API keys
+	router.HandleFunc("/admin/keys", hnd.List).Methods(http.MethodGet).Name("listKeys")
+	// revoke API key
+	router.HandleFunc("/admin/keys/{id}", hnd.Revoke).Methods(http.MethodDelete).Name("revokeKey")
+}
+
+// RegisterHealthRoutes registers the liveness and readiness routes, outside of the api so they
+// are neither authenticated nor rate limited
+func RegisterHealthRoutes(router *mux.Router, hnd handlers.IHealthHandler) {
+	// process is alive
+	router.HandleFunc("/healthz", hnd.Live).Methods(http.MethodGet)
+	// ready to serve requests
+	router.HandleFunc("/readyz", hnd.Ready).Methods(http.MethodGet)
+}
+
+// RegisterTenantRoutes registers the routes provisioning libraries
+func RegisterTenantRoutes(router *mux.Router, hnd handlers.ITenantHandler) {
+	// settings of the library of the request
+	router.HandleFunc("/tenant", hnd.Current).Methods(http.MethodGet).Name("currentTenant")
+	// create library
+	router.HandleFunc("/admin/tenants", hnd.Create).Methods(http.MethodPost).Name("createTenant")
+	// list libraries
+	router.HandleFunc("/admin/tenants", hnd.List).Methods(http.MethodGet).Name("listTenants")
+	// get library
+	router.HandleFunc("/admin/tenants/{id}", hnd.Get).Methods(http.MethodGet).Name("getTenant")
+	// update library settings
+	router.HandleFunc("/admin/tenants/{id}", hnd.Update).Methods(http.MethodPut).Name("updateTenant")
 }
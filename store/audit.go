@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+	"gorm.io/gorm"
+)
+
+// fields left out of the audit changes, they change on every event
+var unauditedFields = map[string]bool{
+	"updated_on": true,
+	"version":    true,
+}
+
+// record writes the audit event of a change from before to after within the given transaction
+func (p *pg) record(ctx context.Context, tx *gorm.DB, ev *objects.AuditEvent, before, after *objects.Book) error {
+	ev.TenantID = objects.TenantFromContext(ctx).ID
+	ev.Actor = objects.ActorFromContext(ctx)
+	ev.Timestamp = p.db.NowFunc()
+	var err error
+	if before != nil {
+		ev.BookID = before.ID
+		ev.Revision = before.Version + 1
+		if ev.Before, err = json.Marshal(snapshot(before)); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		ev.BookID = after.ID
+		ev.Revision = after.Version
+		if ev.After, err = json.Marshal(snapshot(after)); err != nil {
+			return err
+		}
+	}
+	if ev.Changes, err = diff(ev.Before, ev.After); err != nil {
+		return err
+	}
+	return tx.Create(ev).Error
+}
+
+// snapshot copy of the book recorded in the audit log, with when it was put in the trash
+// written as deleted_at, so trashing and restoring it are changes too
+func snapshot(bk *objects.Book) *objects.Book {
+	res := *bk
+	res.DeletedOn = nil
+	if bk.DeletedAt.Valid {
+		deleted := bk.DeletedAt.Time
+		res.DeletedOn = &deleted
+	}
+	return &res
+}
+
+// diff field by field changes between two book snapshots
+func diff(before, after []byte) ([]byte, error) {
+	from, to := map[string]interface{}{}, map[string]interface{}{}
+	if len(before) > 0 {
+		if err := json.Unmarshal(before, &from); err != nil {
+			return nil, err
+		}
+	}
+	if len(after) > 0 {
+		if err := json.Unmarshal(after, &to); err != nil {
+			return nil, err
+		}
+	}
+	changes := map[string]map[string]interface{}{}
+	for _, fields := range []map[string]interface{}{from, to} {
+		for k := range fields {
+			if unauditedFields[k] || reflect.DeepEqual(from[k], to[k]) {
+				continue
+			}
+			changes[k] = map[string]interface{}{"from": from[k], "to": to[k]}
+		}
+	}
+	return json.Marshal(changes)
+}
+
+func (p *pg) History(ctx context.Context, in *objects.HistoryRequest) ([]*objects.AuditEvent, error) {
+	query := inTenant(ctx, p.db.WithContext(ctx)).Where("book_id = ?", in.ID)
+	if !in.At.IsZero() {
+		query = query.Where(`"timestamp" <= ?`, in.At)
+	}
+	list := make([]*objects.AuditEvent, 0)
+	if err := query.Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, errors.ErrBookNotFound
+	}
+	return list, nil
+}
+
+func (p *pg) GetAsOf(ctx context.Context, in *objects.HistoryRequest) (*objects.Book, error) {
+	ev := &objects.AuditEvent{}
+	err := inTenant(ctx, p.db.WithContext(ctx)).
+		Where(`book_id = ? AND "timestamp" <= ?`, in.ID, in.At).
+		Order("id desc").
+		Take(ev).Error
+	if err == gorm.ErrRecordNotFound {
+		// didn't exist yet
+		return nil, errors.ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	bk := &objects.Book{}
+	if len(ev.After) == 0 {
+		// purged
+		return nil, errors.ErrBookNotFound
+	}
+	if err = json.Unmarshal(ev.After, bk); err != nil {
+		return nil, err
+	}
+	if bk.DeletedOn != nil {
+		// in the trash at that time
+		return nil, errors.ErrBookNotFound
+	}
+	return bk, nil
+}
+
+func (p *pg) GetRevision(ctx context.Context, in *objects.RevertRequest) (*objects.Book, error) {
+	ev := &objects.AuditEvent{}
+	err := inTenant(ctx, p.db.WithContext(ctx)).
+		Where("book_id = ? AND revision = ?", in.ID, in.Revision).
+		Order("id").
+		Take(ev).Error
+	if err == gorm.ErrRecordNotFound || (err == nil && len(ev.After) == 0) {
+		return nil, errors.ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// a deleted book may have been restored since, its old revisions are left as they are
+	var deleted int64
+	err = inTenant(ctx, p.db.WithContext(ctx)).Model(&objects.AuditEvent{}).
+		Where("book_id = ? AND id > ? AND action IN ?", in.ID, ev.ID,
+			[]string{string(objects.ActionDelete), string(objects.ActionPurge)}).
+		Count(&deleted).Error
+	if err != nil {
+		return nil, err
+	}
+	if deleted > 0 {
+		return nil, errors.ErrDeletedSinceRevision
+	}
+	old := &objects.Book{}
+	if err = json.Unmarshal(ev.After, old); err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+func (p *pg) Revert(ctx context.Context, in *objects.RevertRequest) (*objects.Book, error) {
+	old, err := p.GetRevision(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return p.change(ctx, &objects.AuditEvent{BookID: in.ID, Action: objects.ActionRevert}, in.Version,
+		p.details(old))
+}
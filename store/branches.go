@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (p *pg) CreateBranch(ctx context.Context, b *objects.Branch) error {
+	b.ID = GenerateUniqueID()
+	b.TenantID = objects.TenantFromContext(ctx).ID
+	b.CreatedOn = p.db.NowFunc()
+	return p.db.WithContext(ctx).Create(b).Error
+}
+
+func (p *pg) ListBranches(ctx context.Context) ([]*objects.Branch, error) {
+	list := make([]*objects.Branch, 0)
+	err := inTenant(ctx, p.db.WithContext(ctx)).Order("name").Find(&list).Error
+	return list, err
+}
+
+func (p *pg) CreateLocation(ctx context.Context, l *objects.Location) error {
+	if _, err := p.branch(ctx, p.db, l.BranchID); err != nil {
+		return err
+	}
+	l.ID = GenerateUniqueID()
+	l.TenantID = objects.TenantFromContext(ctx).ID
+	l.CreatedOn = p.db.NowFunc()
+	return p.db.WithContext(ctx).Create(l).Error
+}
+
+func (p *pg) ListLocations(ctx context.Context, branchID string) ([]*objects.Location, error) {
+	if _, err := p.branch(ctx, p.db, branchID); err != nil {
+		return nil, err
+	}
+	list := make([]*objects.Location, 0)
+	err := inTenant(ctx, p.db.WithContext(ctx)).Where("branch_id = ?", branchID).
+		Order("floor, shelf").Find(&list).Error
+	return list, err
+}
+
+func (p *pg) ShelfList(ctx context.Context, locationID string) (*objects.Location, []*objects.Book, error) {
+	loc, err := p.location(ctx, p.db, locationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	list := make([]*objects.Book, 0)
+	err = inTenant(ctx, p.db.WithContext(ctx)).Where("location_id = ?", loc.ID).
+		Order(listOrder(&objects.ListRequest{Sort: objects.SortShelf})).Find(&list).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	return loc, list, nil
+}
+
+func (p *pg) Move(ctx context.Context, in *objects.MoveRequest) (*objects.Book, error) {
+	var bk *objects.Book
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := p.shelvedBook(ctx, tx, in.ID); err != nil {
+			return err
+		}
+		loc, err := p.location(ctx, tx, in.LocationID)
+		if err != nil {
+			return err
+		}
+		bk, err = (&pg{db: tx}).change(ctx, &objects.AuditEvent{BookID: in.ID, Action: objects.ActionMove}, in.Version,
+			map[string]interface{}{
+				"branch_id":   loc.BranchID,
+				"location_id": loc.ID,
+				"updated_on":  p.db.NowFunc(),
+			})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bk, nil
+}
+
+func (p *pg) Transfer(ctx context.Context, in *objects.TransferRequest) (*objects.Transfer, error) {
+	tr := &objects.Transfer{}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		bk, err := p.shelvedBook(ctx, tx, in.ID)
+		if err != nil {
+			return err
+		}
+		if _, err = p.branch(ctx, tx, in.ToBranchID); err != nil {
+			return err
+		}
+		if bk.BranchID == in.ToBranchID {
+			return errors.ErrSameBranch
+		}
+		if in.ToLocationID != "" {
+			loc, err := p.location(ctx, tx, in.ToLocationID)
+			if err != nil {
+				return err
+			}
+			if loc.BranchID != in.ToBranchID {
+				return errors.ErrLocationNotInBranch
+			}
+		}
+		*tr = objects.Transfer{
+			ID:           GenerateUniqueID(),
+			TenantID:     objects.TenantFromContext(ctx).ID,
+			BookID:       bk.ID,
+			FromBranchID: bk.BranchID,
+			ToBranchID:   in.ToBranchID,
+			ToLocationID: in.ToLocationID,
+			Status:       objects.TransferInTransit,
+			SentOn:       p.db.NowFunc(),
+		}
+		if err = tx.Create(tr).Error; err != nil {
+			return err
+		}
+		// off the shelves of any branch until received
+		_, err = (&pg{db: tx}).change(ctx, &objects.AuditEvent{BookID: bk.ID, Action: objects.ActionTransfer}, in.Version,
+			map[string]interface{}{
+				"branch_id":   "",
+				"location_id": "",
+				"transfer_id": tr.ID,
+				"updated_on":  p.db.NowFunc(),
+			})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+func (p *pg) ListTransfers(ctx context.Context, in *objects.ListTransfersRequest) ([]*objects.Transfer, error) {
+	query := inTenant(ctx, p.db.WithContext(ctx))
+	if in.Status != "" {
+		query = query.Where("status = ?", in.Status)
+	}
+	if in.BranchID != "" {
+		query = query.Where("from_branch_id = ? OR to_branch_id = ?", in.BranchID, in.BranchID)
+	}
+	list := make([]*objects.Transfer, 0)
+	err := query.Order("sent_on desc").Find(&list).Error
+	return list, err
+}
+
+func (p *pg) Receive(ctx context.Context, in *objects.ReceiveRequest) (*objects.Transfer, error) {
+	tr := &objects.Transfer{}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := inTenant(ctx, tx).Clauses(clause.Locking{Strength: "UPDATE"}).Take(tr, "id = ?", in.ID).Error
+		if err == gorm.ErrRecordNotFound {
+			return errors.ErrTransferNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if tr.Status == objects.TransferReceived {
+			return errors.ErrTransferReceived
+		}
+		locationID := in.LocationID
+		if locationID == "" {
+			locationID = tr.ToLocationID
+		}
+		if locationID != "" {
+			loc, err := p.location(ctx, tx, locationID)
+			if err != nil {
+				return err
+			}
+			if loc.BranchID != tr.ToBranchID {
+				return errors.ErrLocationNotInBranch
+			}
+		}
+		now := p.db.NowFunc()
+		tr.Status, tr.ReceivedOn, tr.ToLocationID = objects.TransferReceived, &now, locationID
+		if err = tx.Save(tr).Error; err != nil {
+			return err
+		}
+		_, err = (&pg{db: tx}).change(ctx, &objects.AuditEvent{BookID: tr.BookID, Action: objects.ActionReceive}, 0,
+			map[string]interface{}{
+				"branch_id":   tr.ToBranchID,
+				"location_id": locationID,
+				"transfer_id": "",
+				"updated_on":  now,
+			})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// shelvedBook locks a book for a change of location, ErrBookInTransit when it can only be received
+func (p *pg) shelvedBook(ctx context.Context, tx *gorm.DB, id string) (*objects.Book, error) {
+	bk := &objects.Book{}
+	err := inTenant(ctx, tx).Clauses(clause.Locking{Strength: "UPDATE"}).Take(bk, "id = ?", id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bk.TransferID != "" {
+		return nil, errors.ErrBookInTransit
+	}
+	return bk, nil
+}
+
+// branch branch of the library with the given id
+func (p *pg) branch(ctx context.Context, db *gorm.DB, id string) (*objects.Branch, error) {
+	b := &objects.Branch{}
+	err := inTenant(ctx, db.WithContext(ctx)).Take(b, "id = ?", id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.ErrBranchNotFound
+	}
+	return b, err
+}
+
+// location location of the library with the given id
+func (p *pg) location(ctx context.Context, db *gorm.DB, id string) (*objects.Location, error) {
+	l := &objects.Location{}
+	err := inTenant(ctx, db.WithContext(ctx)).Take(l, "id = ?", id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.ErrLocationNotFound
+	}
+	return l, err
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// IHealthStore is the interface checking a store can serve requests
+type IHealthStore interface {
+	// Ping checks the database can be reached
+	Ping(ctx context.Context) error
+	// Migrated checks the tables and columns of the store's models exist, e.g they weren't
+	// dropped or migrated back by an older version
+	Migrated(ctx context.Context) error
+}
+
+// ping checks the database can be reached
+func ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// migrated checks the tables and columns of the models exist, in one query
+func migrated(ctx context.Context, db *gorm.DB, models ...interface{}) error {
+	var columns []struct{ TableName, ColumnName string }
+	err := db.WithContext(ctx).Raw(`SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = CURRENT_SCHEMA()`).Scan(&columns).Error
+	if err != nil {
+		return err
+	}
+	exists := map[string]bool{}
+	for _, c := range columns {
+		exists[c.TableName+"."+c.ColumnName] = true
+	}
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		for _, f := range stmt.Schema.Fields {
+			if f.DBName != "" && !exists[stmt.Schema.Table+"."+f.DBName] {
+				return fmt.Errorf("column %s.%s is missing", stmt.Schema.Table, f.DBName)
+			}
+		}
+	}
+	return nil
+}
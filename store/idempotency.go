@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+
+	"github.com/redeam/gobooks/objects"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (p *pg) ReserveIdempotencyKey(ctx context.Context, k *objects.IdempotencyKey) (*objects.IdempotencyKey, error) {
+	k.TenantID = objects.TenantFromContext(ctx).ID
+	k.Status, k.CreatedOn = 0, p.db.NowFunc()
+	var existing *objects.IdempotencyKey
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// expired keys, and those abandoned while their request was being made, can be used again
+		err := tx.Where("created_on < ? OR (status = 0 AND created_on < ?)",
+			k.CreatedOn.Add(-objects.DefaultIdempotencyRetention), k.CreatedOn.Add(-objects.IdempotencyKeyLease)).
+			Delete(&objects.IdempotencyKey{}).Error
+		if err != nil {
+			return err
+		}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(k)
+		if res.Error != nil || res.RowsAffected == 1 {
+			return res.Error
+		}
+		existing = &objects.IdempotencyKey{}
+		return tx.Take(existing, "tenant_id = ? AND client = ? AND key = ?", k.TenantID, k.Client, k.Key).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+func (p *pg) CompleteIdempotencyKey(ctx context.Context, k *objects.IdempotencyKey) error {
+	return p.db.WithContext(ctx).Model(&objects.IdempotencyKey{}).
+		Where("tenant_id = ? AND client = ? AND key = ?", k.TenantID, k.Client, k.Key).
+		Updates(map[string]interface{}{
+			"status":       k.Status,
+			"content_type": k.ContentType,
+			"etag":         k.ETag,
+			"body":         k.Body,
+		}).Error
+}
+
+func (p *pg) ReleaseIdempotencyKey(ctx context.Context, k *objects.IdempotencyKey) error {
+	return p.db.WithContext(ctx).
+		Where("tenant_id = ? AND client = ? AND key = ?", k.TenantID, k.Client, k.Key).
+		Delete(&objects.IdempotencyKey{}).Error
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+	"gorm.io/gorm"
+)
+
+// keyPrefix prefix of the API key secrets, so leaked keys are easy to recognize
+const keyPrefix = "gbk_"
+
+// IKeyStore is the database interface for storing API keys
+type IKeyStore interface {
+	// IssueKey creates a key, returned with its secret
+	IssueKey(ctx context.Context, in *objects.IssueKeyRequest) (*objects.APIKey, error)
+	ListKeys(ctx context.Context) ([]*objects.APIKey, error)
+	RevokeKey(ctx context.Context, in *objects.RevokeKeyRequest) error
+	// FindKey the key with the given secret, of any library, ErrUnauthorized when it is unknown or
+	// revoked
+	FindKey(ctx context.Context, secret string) (*objects.APIKey, error)
+	IHealthStore
+	// Close closes the connections to the database
+	Close() error
+}
+
+type pgKeys struct {
+	db *gorm.DB
+}
+
+// NewPostgresKeyStore returns a postgres implementation of API key store
+func NewPostgresKeyStore(conn string) IKeyStore {
+	return &pgKeys{db: connect(conn, &objects.APIKey{})}
+}
+
+func (p *pgKeys) Ping(ctx context.Context) error {
+	return ping(ctx, p.db)
+}
+
+func (p *pgKeys) Migrated(ctx context.Context) error {
+	return migrated(ctx, p.db, &objects.APIKey{})
+}
+
+func (p *pgKeys) IssueKey(ctx context.Context, in *objects.IssueKeyRequest) (*objects.APIKey, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	key := &objects.APIKey{
+		ID:       GenerateUniqueID(),
+		TenantID: objects.TenantFromContext(ctx).ID,
+		Name:     in.Name,
+		Scope:    in.Scope,
+		Secret:   keyPrefix + hex.EncodeToString(secret),
+	}
+	key.Hash = hashKey(key.Secret)
+	key.CreatedOn = p.db.NowFunc()
+	if err := p.db.WithContext(ctx).Create(key).Error; err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (p *pgKeys) ListKeys(ctx context.Context) ([]*objects.APIKey, error) {
+	var list []*objects.APIKey
+	err := inTenant(ctx, p.db.WithContext(ctx)).Order("created_on").Find(&list).Error
+	return list, err
+}
+
+func (p *pgKeys) RevokeKey(ctx context.Context, in *objects.RevokeKeyRequest) error {
+	res := inTenant(ctx, p.db.WithContext(ctx)).Model(&objects.APIKey{}).
+		Where("id = ? AND revoked_on IS NULL", in.ID).
+		Update("revoked_on", p.db.NowFunc())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.ErrKeyNotFound
+	}
+	return nil
+}
+
+func (p *pgKeys) FindKey(ctx context.Context, secret string) (*objects.APIKey, error) {
+	key := &objects.APIKey{}
+	err := p.db.WithContext(ctx).Take(key, "hash = ? AND revoked_on IS NULL", hashKey(secret)).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.ErrUnauthorized
+	}
+	return key, err
+}
+
+// hashKey hash of a key secret, secrets are random enough for a fast hash
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func (p *pgKeys) Close() error {
+	return closeDB(p.db)
+}
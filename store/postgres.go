@@ -2,14 +2,18 @@ package store
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
+	"github.com/jackc/pgconn"
 	"github.com/redeam/gobooks/errors"
 	"github.com/redeam/gobooks/objects"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -17,8 +21,18 @@ type pg struct {
 	db *gorm.DB
 }
 
+// bookModels models of the tables of the Book store
+var bookModels = []interface{}{&objects.Book{}, &objects.AuditEvent{},
+	&objects.Branch{}, &objects.Location{}, &objects.Transfer{}, &objects.IdempotencyKey{}}
+
 // NewPostgresBookStore returns a postgres implementation of Book store
 func NewPostgresBookStore(conn string) IBookStore {
+	// return store implementation
+	return &pg{db: connect(conn, bookModels...)}
+}
+
+// connect opens a database connection, migrating the tables of the given models
+func connect(conn string, models ...interface{}) *gorm.DB {
 	// create database connection
 	db, err := gorm.Open(postgres.Open(conn),
 		&gorm.Config{
@@ -34,17 +48,37 @@ func NewPostgresBookStore(conn string) IBookStore {
 	if err != nil {
 		panic("Enable to connect to database: " + err.Error())
 	}
-	if err := db.AutoMigrate(&objects.Book{}); err != nil {
+	if err := db.AutoMigrate(models...); err != nil {
 		panic("Enable to migrate database: " + err.Error())
 	}
-	// return store implementation
-	return &pg{db: db}
+	return db
+}
+
+// closeDB closes the connection pool of a database
+func closeDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// inTenant restricts a query to the rows of the library of the request, so the ids of other
+// libraries are never found
+func inTenant(ctx context.Context, db *gorm.DB) *gorm.DB {
+	return db.Where("tenant_id = ?", objects.TenantFromContext(ctx).ID)
+}
+
+// uniqueViolation reports whether err is the violation of the unique index of the given name
+func uniqueViolation(err error, index string) bool {
+	var pgErr *pgconn.PgError
+	return stderrors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == index
 }
 
 func (p *pg) Get(ctx context.Context, in *objects.GetRequest) (*objects.Book, error) {
 	bk := &objects.Book{}
 	// take book where id == uid from database
-	err := p.db.WithContext(ctx).Take(bk, "id = ?", in.ID).Error
+	err := inTenant(ctx, p.db.WithContext(ctx)).Take(bk, "id = ?", in.ID).Error
 	if err == gorm.ErrRecordNotFound {
 		// not found
 		return nil, errors.ErrBookNotFound
@@ -56,48 +90,282 @@ func (p *pg) List(ctx context.Context, in *objects.ListRequest) ([]*objects.Book
 	if in.Limit == 0 || in.Limit > objects.MaxListLimit {
 		in.Limit = objects.MaxListLimit
 	}
-	query := p.db.WithContext(ctx).Limit(in.Limit)
-	if in.Title != "" {
-		query = query.Where("title ilike ?", "%"+in.Title+"%")
+	query := listFilters(inTenant(ctx, p.db.WithContext(ctx)).Limit(in.Limit), in)
+	if in.WithTrash {
+		query = query.Unscoped()
 	}
 	list := make([]*objects.Book, 0, in.Limit)
 	fmt.Println(list)
-	err := query.Order("id").Find(&list).Error
-	return list, err
+	if err := query.Order(listOrder(in)).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for _, bk := range list {
+		if bk.DeletedAt.Valid {
+			deleted := bk.DeletedAt.Time
+			bk.DeletedOn = &deleted
+		}
+	}
+	return list, nil
+}
+
+func (p *pg) Stream(ctx context.Context, in *objects.ListRequest, fn func(bk *objects.Book) error) error {
+	query := listFilters(inTenant(ctx, p.db.WithContext(ctx)).Model(&objects.Book{}), in)
+	if in.Limit > 0 {
+		query = query.Limit(in.Limit)
+	}
+	rows, err := query.Order(listOrder(in)).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		bk := &objects.Book{}
+		if err := p.db.ScanRows(rows, bk); err != nil {
+			return err
+		}
+		if err := fn(bk); err != nil {
+			return err
+		}
+	}
+	// a cancelled context ends the rows early, with its error
+	return rows.Err()
+}
+
+// listOrder order of the books of a list request. Shelf keys are compared byte by byte, whatever
+// the collation of the database, and books without a call number come last.
+func listOrder(in *objects.ListRequest) string {
+	if in.Sort == objects.SortShelf {
+		return `shelf_key = '', shelf_key COLLATE "C", id`
+	}
+	return "id"
+}
+
+// listFilters applies the filters of a list request to a query
+func listFilters(query *gorm.DB, in *objects.ListRequest) *gorm.DB {
+	if in.Title != "" {
+		query = query.Where("title ilike ?", "%"+in.Title+"%")
+	}
+	if in.ISBN != "" {
+		query = query.Where("isbn = ?", in.ISBN)
+	}
+	if in.RecordReference != "" {
+		query = query.Where("record_reference = ?", in.RecordReference)
+	}
+	if in.Branch != "" {
+		query = query.Where("branch_id = ?", in.Branch)
+	}
+	if in.After != "" {
+		query = query.Where("id > ?", in.After)
+	}
+	return query
 }
 
 func (p *pg) Create(ctx context.Context, in *objects.CreateRequest) error {
 	if in.Book == nil {
 		return errors.ErrObjectIsRequired
 	}
+	// books are created on the shelves of their location, they can only be transferred later
+	in.Book.BranchID, in.Book.TransferID = "", ""
+	if in.Book.LocationID != "" {
+		loc, err := p.location(ctx, p.db, in.Book.LocationID)
+		if err != nil {
+			return err
+		}
+		in.Book.BranchID = loc.BranchID
+	}
 	in.Book.ID = GenerateUniqueID()
+	in.Book.ShelfKey = objects.ShelfKey(in.Book.CallNumber)
+	in.Book.TenantID = objects.TenantFromContext(ctx).ID
+	in.Book.Version = 1
+	in.Book.DeletedAt = gorm.DeletedAt{}
 
 	in.Book.CreatedOn = p.db.NowFunc()
-	return p.db.WithContext(ctx).
-		Create(in.Book).
-		Error
+	in.Book.DueOn = p.dueOn(ctx, nil, in.Book.Status)
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(in.Book).Error; err != nil {
+			if uniqueViolation(err, "idx_books_record_reference") {
+				return errors.ErrRecordReferenceExists
+			}
+			return err
+		}
+		return p.record(ctx, tx, &objects.AuditEvent{Action: objects.ActionCreate}, nil, in.Book)
+	})
 }
 
 func (p *pg) UpdateDetails(ctx context.Context, in *objects.UpdateDetailsRequest) error {
-	bk := &objects.Book{
-		ID:          in.ID,
-		Title:       in.Title,
-		Author:      in.Author,
-		PublishDate: in.PublishDate,
-		Publisher:   in.Publisher,
-		Status:      in.Status,
-		Rating:      in.Rating,
-		UpdatedOn:   p.db.NowFunc(),
-	}
-	return p.db.WithContext(ctx).Model(bk).
-		Select("title", "author", "publishdate", "status", "rating", "updated_on").
-		Updates(bk).
-		Error
+	_, err := p.change(ctx, &objects.AuditEvent{BookID: in.ID, Action: objects.ActionUpdate}, in.Version,
+		p.details(&objects.Book{
+			ISBN:        in.ISBN,
+			Title:       in.Title,
+			Author:      in.Author,
+			PublishDate: in.PublishDate,
+			Publisher:   in.Publisher,
+			Status:      in.Status,
+			Rating:      in.Rating,
+			CallNumber:  in.CallNumber,
+		}))
+	return err
+}
+
+func (p *pg) Update(ctx context.Context, in *objects.UpdateRequest) error {
+	if in.Book == nil {
+		return errors.ErrObjectIsRequired
+	}
+	bk, err := p.change(ctx, &objects.AuditEvent{BookID: in.Book.ID, Action: objects.ActionUpdate}, in.Book.Version,
+		p.details(in.Book))
+	if err != nil {
+		return err
+	}
+	*in.Book = *bk
+	return nil
 }
 
 func (p *pg) Delete(ctx context.Context, in *objects.DeleteRequest) error {
-	bk := &objects.Book{ID: in.ID}
-	return p.db.WithContext(ctx).Model(bk).
-		Delete(bk).
-		Error
+	_, err := p.change(ctx, &objects.AuditEvent{BookID: in.ID, Action: objects.ActionDelete}, in.Version,
+		map[string]interface{}{
+			"deleted_at": p.db.NowFunc(),
+		})
+	return err
+}
+
+func (p *pg) ListTrash(ctx context.Context, in *objects.ListRequest) ([]*objects.Book, error) {
+	if in.Limit == 0 || in.Limit > objects.MaxListLimit {
+		in.Limit = objects.MaxListLimit
+	}
+	query := inTenant(ctx, p.db.WithContext(ctx)).Unscoped().Where("deleted_at IS NOT NULL").Limit(in.Limit)
+	if in.Title != "" {
+		query = query.Where("title ilike ?", "%"+in.Title+"%")
+	}
+	list := make([]*objects.Book, 0, in.Limit)
+	if err := query.Order("deleted_at desc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for _, bk := range list {
+		deleted := bk.DeletedAt.Time
+		bk.DeletedOn = &deleted
+	}
+	return list, nil
+}
+
+func (p *pg) Restore(ctx context.Context, in *objects.RestoreRequest) error {
+	_, err := p.change(ctx, &objects.AuditEvent{BookID: in.ID, Action: objects.ActionRestore}, 0,
+		map[string]interface{}{
+			"deleted_at": nil,
+			"updated_on": p.db.NowFunc(),
+		})
+	return err
+}
+
+func (p *pg) Purge(ctx context.Context, in *objects.PurgeRequest) (int64, error) {
+	var purged []*objects.Book
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := inTenant(ctx, tx).Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", in.Before).
+			Find(&purged).Error
+		if err != nil || len(purged) == 0 {
+			return err
+		}
+		ids := make([]string, 0, len(purged))
+		for _, bk := range purged {
+			ids = append(ids, bk.ID)
+			if err := p.record(ctx, tx, &objects.AuditEvent{Action: objects.ActionPurge}, bk, nil); err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Where("id IN ?", ids).Delete(&objects.Book{}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(purged)), nil
+}
+
+func (p *pg) Ping(ctx context.Context) error {
+	return ping(ctx, p.db)
+}
+
+func (p *pg) Migrated(ctx context.Context) error {
+	return migrated(ctx, p.db, bookModels...)
+}
+
+func (p *pg) Close() error {
+	return closeDB(p.db)
+}
+
+func (p *pg) Transaction(ctx context.Context, fn func(st IBookStore) error) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&pg{db: tx})
+	})
+}
+
+// details updates of every general detail of the book, so cleared fields are written as well
+func (p *pg) details(bk *objects.Book) map[string]interface{} {
+	return map[string]interface{}{
+		"isbn":         bk.ISBN,
+		"title":        bk.Title,
+		"author":       bk.Author,
+		"publisher":    bk.Publisher,
+		"publish_date": bk.PublishDate,
+		"status":       bk.Status,
+		"rating":       bk.Rating,
+		"call_number":  bk.CallNumber,
+		"shelf_key":    objects.ShelfKey(bk.CallNumber),
+		"updated_on":   p.db.NowFunc(),
+	}
+}
+
+// change applies the updates to a book and records the audit event in one transaction,
+// bumping the book's version. Trashed books can only be restored, and when version
+// isn't zero the book must still be at that version.
+func (p *pg) change(ctx context.Context, ev *objects.AuditEvent, version int64, updates map[string]interface{}) (*objects.Book, error) {
+	after := &objects.Book{}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before := &objects.Book{}
+		query := inTenant(ctx, tx).Unscoped().Clauses(clause.Locking{Strength: "UPDATE"})
+		if ev.Action == objects.ActionRestore {
+			query = query.Where("deleted_at IS NOT NULL")
+		} else {
+			query = query.Where("deleted_at IS NULL")
+		}
+		err := query.Take(before, "id = ?", ev.BookID).Error
+		if err == gorm.ErrRecordNotFound {
+			return errors.ErrBookNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if version != 0 && before.Version != version {
+			return errors.ErrPreconditionFailed
+		}
+		updates["version"] = before.Version + 1
+		if status, ok := updates["status"]; ok {
+			updates["due_on"] = p.dueOn(ctx, before, status)
+		}
+		err = tx.Unscoped().Model(&objects.Book{}).Where("id = ?", ev.BookID).Updates(updates).Error
+		if err != nil {
+			return err
+		}
+		if err = tx.Unscoped().Take(after, "id = ?", ev.BookID).Error; err != nil {
+			return err
+		}
+		return p.record(ctx, tx, ev, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// dueOn due date of a book whose status becomes status: checked out books are due after the
+// loan period of their library, and checked in ones aren't due
+func (p *pg) dueOn(ctx context.Context, before *objects.Book, status interface{}) *time.Time {
+	if status != objects.CheckedOut {
+		return nil
+	}
+	if before != nil && before.Status == objects.CheckedOut {
+		// still checked out
+		return before.DueOn
+	}
+	due := p.db.NowFunc().Add(objects.TenantFromContext(ctx).LoanPeriod())
+	return &due
 }
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redeam/gobooks/objects"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rateSweepInterval how often the memory store forgets the buckets that are full again
+const rateSweepInterval = time.Minute
+
+// IRateStore is the interface of the token buckets limiting the rate of requests, shared by
+// the replicas of the server when it isn't kept in memory
+type IRateStore interface {
+	// Take takes a token from the bucket with the given key, limited by l
+	Take(ctx context.Context, key string, l objects.RateLimit) (*objects.RateResult, error)
+	IHealthStore
+	// Close closes the connections to the database, if any
+	Close() error
+}
+
+type memoryRates struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	objects.RateBucket
+	// full when the bucket is full again, and can be forgotten
+	full time.Time
+}
+
+// NewMemoryRateStore returns a rate store kept in memory, limiting the requests made to this
+// replica only
+func NewMemoryRateStore() IRateStore {
+	return &memoryRates{buckets: map[string]*memoryBucket{}}
+}
+
+func (m *memoryRates) Take(ctx context.Context, key string, l objects.RateLimit) (*objects.RateResult, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) > rateSweepInterval {
+		for k, b := range m.buckets {
+			if now.After(b.full) {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &memoryBucket{RateBucket: objects.RateBucket{Key: key}}
+		m.buckets[key] = b
+	}
+	res := b.Take(l, now)
+	b.full = now.Add(res.Reset)
+	return res, nil
+}
+
+// rateBucketTTL how long the postgres store keeps buckets nobody takes from, by then they are
+// full again unless their period is longer
+const rateBucketTTL = 24 * time.Hour
+
+func (m *memoryRates) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *memoryRates) Migrated(ctx context.Context) error {
+	return nil
+}
+
+func (m *memoryRates) Close() error {
+	return nil
+}
+
+type pgRates struct {
+	db        *gorm.DB
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewPostgresRateStore returns a postgres implementation of rate store, shared by the replicas
+// using the same database
+func NewPostgresRateStore(conn string) IRateStore {
+	return &pgRates{db: connect(conn, &objects.RateBucket{})}
+}
+
+func (p *pgRates) Ping(ctx context.Context) error {
+	return ping(ctx, p.db)
+}
+
+func (p *pgRates) Migrated(ctx context.Context) error {
+	return migrated(ctx, p.db, &objects.RateBucket{})
+}
+
+func (p *pgRates) Take(ctx context.Context, key string, l objects.RateLimit) (*objects.RateResult, error) {
+	now := p.db.NowFunc()
+	p.sweep(ctx, now)
+	var res *objects.RateResult
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// new buckets are full
+		bucket := &objects.RateBucket{Key: key, Tokens: float64(l.Requests), UpdatedOn: now}
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(bucket).Error
+		if err != nil {
+			return err
+		}
+		b := &objects.RateBucket{}
+		if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(b, "key = ?", key).Error; err != nil {
+			return err
+		}
+		res = b.Take(l, now)
+		return tx.Save(b).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// sweep deletes the buckets nobody took from for rateBucketTTL, once per rateSweepInterval
+func (p *pgRates) sweep(ctx context.Context, now time.Time) {
+	p.mu.Lock()
+	due := now.Sub(p.lastSweep) > rateSweepInterval
+	if due {
+		p.lastSweep = now
+	}
+	p.mu.Unlock()
+	if due {
+		p.db.WithContext(ctx).Where("updated_on < ?", now.Add(-rateBucketTTL)).Delete(&objects.RateBucket{})
+	}
+}
+
+func (p *pgRates) Close() error {
+	return closeDB(p.db)
+}
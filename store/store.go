@@ -13,9 +13,47 @@ import (
 type IBookStore interface {
 	Get(ctx context.Context, in *objects.GetRequest) (*objects.Book, error)
 	List(ctx context.Context, in *objects.ListRequest) ([]*objects.Book, error)
+	// Stream calls fn with every book matching the list filters, reading them one at a time from a
+	// database cursor. A zero limit streams them all.
+	Stream(ctx context.Context, in *objects.ListRequest, fn func(bk *objects.Book) error) error
 	Create(ctx context.Context, in *objects.CreateRequest) error
 	UpdateDetails(ctx context.Context, in *objects.UpdateDetailsRequest) error
+	Update(ctx context.Context, in *objects.UpdateRequest) error
 	Delete(ctx context.Context, in *objects.DeleteRequest) error
+	ListTrash(ctx context.Context, in *objects.ListRequest) ([]*objects.Book, error)
+	Restore(ctx context.Context, in *objects.RestoreRequest) error
+	Purge(ctx context.Context, in *objects.PurgeRequest) (int64, error)
+	History(ctx context.Context, in *objects.HistoryRequest) ([]*objects.AuditEvent, error)
+	GetAsOf(ctx context.Context, in *objects.HistoryRequest) (*objects.Book, error)
+	// GetRevision book as of a revision, ErrDeletedSinceRevision when it was deleted since
+	GetRevision(ctx context.Context, in *objects.RevertRequest) (*objects.Book, error)
+	Revert(ctx context.Context, in *objects.RevertRequest) (*objects.Book, error)
+	CreateBranch(ctx context.Context, b *objects.Branch) error
+	ListBranches(ctx context.Context) ([]*objects.Branch, error)
+	// CreateLocation creates a location of a branch, ErrBranchNotFound when there is none
+	CreateLocation(ctx context.Context, l *objects.Location) error
+	ListLocations(ctx context.Context, branchID string) ([]*objects.Location, error)
+	// ShelfList books shelved at a location, in shelf order
+	ShelfList(ctx context.Context, locationID string) (*objects.Location, []*objects.Book, error)
+	// Move shelves a book at a location, ErrBookInTransit while it is sent to another branch
+	Move(ctx context.Context, in *objects.MoveRequest) (*objects.Book, error)
+	// Transfer sends a book to another branch, it is in transit until received
+	Transfer(ctx context.Context, in *objects.TransferRequest) (*objects.Transfer, error)
+	ListTransfers(ctx context.Context, in *objects.ListTransfersRequest) ([]*objects.Transfer, error)
+	// Receive receives a transferred book, shelving it at the location of the transfer if any
+	Receive(ctx context.Context, in *objects.ReceiveRequest) (*objects.Transfer, error)
+	// ReserveIdempotencyKey reserves the key of a request, returning the request already made with
+	// it if any, nil when it was reserved. Keys expire after DefaultIdempotencyRetention
+	ReserveIdempotencyKey(ctx context.Context, k *objects.IdempotencyKey) (*objects.IdempotencyKey, error)
+	// CompleteIdempotencyKey records the response of the request made with a reserved key
+	CompleteIdempotencyKey(ctx context.Context, k *objects.IdempotencyKey) error
+	// ReleaseIdempotencyKey forgets a reserved key, so the request can be made with it again
+	ReleaseIdempotencyKey(ctx context.Context, k *objects.IdempotencyKey) error
+	IHealthStore
+	// Transaction runs fn with a store whose changes are all committed, or none when fn fails
+	Transaction(ctx context.Context, fn func(st IBookStore) error) error
+	// Close closes the connections to the database
+	Close() error
 }
 
 func init() {
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+
+	"github.com/redeam/gobooks/errors"
+	"github.com/redeam/gobooks/objects"
+	"gorm.io/gorm"
+)
+
+// ITenantStore is the database interface for storing the libraries of the deployment
+type ITenantStore interface {
+	// CreateTenant creates a library, ErrTenantExists when its id is taken
+	CreateTenant(ctx context.Context, t *objects.Tenant) error
+	// GetTenant the library with the given id, ErrTenantNotFound when there is none
+	GetTenant(ctx context.Context, id string) (*objects.Tenant, error)
+	ListTenants(ctx context.Context) ([]*objects.Tenant, error)
+	// UpdateTenant replaces the name and settings of a library
+	UpdateTenant(ctx context.Context, t *objects.Tenant) error
+	IHealthStore
+	// Close closes the connections to the database
+	Close() error
+}
+
+type pgTenants struct {
+	db *gorm.DB
+}
+
+// NewPostgresTenantStore returns a postgres implementation of library store, with the default
+// library the rows made before libraries existed belong to
+func NewPostgresTenantStore(conn string) ITenantStore {
+	db := connect(conn, &objects.Tenant{})
+	def := *objects.DefaultTenant
+	def.CreatedOn = db.NowFunc()
+	if err := db.FirstOrCreate(&def, "id = ?", def.ID).Error; err != nil {
+		panic("Unable to create the default library: " + err.Error())
+	}
+	return &pgTenants{db: db}
+}
+
+func (p *pgTenants) Ping(ctx context.Context) error {
+	return ping(ctx, p.db)
+}
+
+func (p *pgTenants) Migrated(ctx context.Context) error {
+	return migrated(ctx, p.db, &objects.Tenant{})
+}
+
+func (p *pgTenants) CreateTenant(ctx context.Context, t *objects.Tenant) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&objects.Tenant{}).Where("id = ?", t.ID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return errors.ErrTenantExists
+		}
+		t.CreatedOn = p.db.NowFunc()
+		return tx.Create(t).Error
+	})
+}
+
+func (p *pgTenants) GetTenant(ctx context.Context, id string) (*objects.Tenant, error) {
+	t := &objects.Tenant{}
+	err := p.db.WithContext(ctx).Take(t, "id = ?", id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.ErrTenantNotFound
+	}
+	return t, err
+}
+
+func (p *pgTenants) ListTenants(ctx context.Context) ([]*objects.Tenant, error) {
+	var list []*objects.Tenant
+	err := p.db.WithContext(ctx).Order("id").Find(&list).Error
+	return list, err
+}
+
+func (p *pgTenants) UpdateTenant(ctx context.Context, t *objects.Tenant) error {
+	res := p.db.WithContext(ctx).Model(&objects.Tenant{}).Where("id = ?", t.ID).
+		Updates(map[string]interface{}{
+			"name":             t.Name,
+			"loan_period_days": t.LoanPeriodDays,
+			"max_rating":       t.MaxRating,
+			"updated_on":       p.db.NowFunc(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.ErrTenantNotFound
+	}
+	updated, err := p.GetTenant(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	*t = *updated
+	return nil
+}
+
+func (p *pgTenants) Close() error {
+	return closeDB(p.db)
+}